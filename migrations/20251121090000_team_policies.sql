@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- team policies (one row per team)
+CREATE TABLE IF NOT EXISTS team_policies (
+    team_id VARCHAR(255) PRIMARY KEY REFERENCES teams(id) ON DELETE CASCADE,
+    reviewer_count INTEGER NOT NULL DEFAULT 2,
+    min_approvals INTEGER NOT NULL DEFAULT 0,
+    allow_cross_team BOOLEAN NOT NULL DEFAULT false
+);
+
+-- users that are never auto-assigned as reviewers in the team
+CREATE TABLE IF NOT EXISTS team_policy_exclusions (
+    team_id VARCHAR(255) NOT NULL REFERENCES team_policies(team_id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (team_id, user_id)
+);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS team_policy_exclusions;
+DROP TABLE IF EXISTS team_policies;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS merged_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_prs_created_at ON prs(created_at);
+
+-- уже смерженные PR получают приблизительное время слияния
+UPDATE prs SET merged_at = updated_at WHERE status = 'MERGED' AND merged_at IS NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_prs_created_at;
+ALTER TABLE prs DROP COLUMN IF EXISTS merged_at;
+ALTER TABLE prs DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE prs DROP COLUMN IF EXISTS created_at;
+-- +goose StatementEnd
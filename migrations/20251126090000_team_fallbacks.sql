@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- команды, в которых ищутся ревьюверы, если в своей команде никто не подходит
+CREATE TABLE IF NOT EXISTS team_fallbacks (
+    team_id VARCHAR(255) NOT NULL REFERENCES team_policies(team_id) ON DELETE CASCADE,
+    fallback_team_id VARCHAR(255) NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (team_id, fallback_team_id)
+);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS team_fallbacks;
+-- +goose StatementEnd
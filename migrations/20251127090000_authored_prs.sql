@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- лид команды, которому передаются PR деактивированных участников
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS lead_id VARCHAR(255);
+
+-- PR, автор которого деактивирован, а PR оставлен открытым
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS author_inactive BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE prs DROP COLUMN IF EXISTS author_inactive;
+ALTER TABLE teams DROP COLUMN IF EXISTS lead_id;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- активные пользователи, временно исключенные из автоназначения ревьюверов
+ALTER TABLE users ADD COLUMN IF NOT EXISTS out_of_rotation BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS out_of_rotation;
+-- +goose StatementEnd
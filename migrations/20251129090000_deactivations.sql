@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- партии деактивации и выполненные в них замены ревьюверов
+CREATE TABLE IF NOT EXISTS deactivations (
+    id VARCHAR(255) PRIMARY KEY,
+    team_id VARCHAR(255) NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_deactivations_team_id ON deactivations(team_id);
+
+CREATE TABLE IF NOT EXISTS deactivation_users (
+    deactivation_id VARCHAR(255) NOT NULL REFERENCES deactivations(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (deactivation_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS deactivation_reassignments (
+    id BIGSERIAL PRIMARY KEY,
+    deactivation_id VARCHAR(255) NOT NULL REFERENCES deactivations(id) ON DELETE CASCADE,
+    pr_id VARCHAR(255) NOT NULL,
+    from_reviewer VARCHAR(255) NOT NULL,
+    to_reviewer VARCHAR(255) NOT NULL,
+    replacement_team TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_deactivation_reassignments_deactivation_id ON deactivation_reassignments(deactivation_id);
+CREATE INDEX IF NOT EXISTS idx_deactivation_reassignments_from_reviewer ON deactivation_reassignments(from_reviewer);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS deactivation_reassignments;
+DROP TABLE IF EXISTS deactivation_users;
+DROP TABLE IF EXISTS deactivations;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- запланированные периоды отсутствия пользователей
+CREATE TABLE IF NOT EXISTS absences (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(50) NOT NULL DEFAULT 'SCHEDULED',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_absences_user_id ON absences(user_id);
+CREATE INDEX IF NOT EXISTS idx_absences_starts_at ON absences(starts_at);
+CREATE INDEX IF NOT EXISTS idx_absences_ends_at ON absences(ends_at);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS absences;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- лимит открытых ревью пользователя, 0 - без лимита
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_open_reviews INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS max_open_reviews;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- команда, по которой подбираются ревьюверы PR, и основная команда пользователя
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS team_id VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_prs_team_id ON prs(team_id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS primary_team_id VARCHAR(255);
+
+-- существующим PR проставляется первая по имени команда автора
+UPDATE prs SET team_id = (
+    SELECT teams.id FROM team_users
+    JOIN teams ON teams.id = team_users.team_id
+    WHERE team_users.user_id = prs.author_id
+    ORDER BY teams.name
+    LIMIT 1
+) WHERE team_id IS NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS primary_team_id;
+DROP INDEX IF EXISTS idx_prs_team_id;
+ALTER TABLE prs DROP COLUMN IF EXISTS team_id;
+-- +goose StatementEnd
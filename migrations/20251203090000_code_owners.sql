@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- правила владения кодом команды в синтаксисе CODEOWNERS и измененные пути PR
+CREATE TABLE IF NOT EXISTS code_owner_rules (
+    id BIGSERIAL PRIMARY KEY,
+    team_id VARCHAR(255) NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    pattern TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_code_owner_rules_team_id ON code_owner_rules(team_id);
+
+CREATE TABLE IF NOT EXISTS code_owner_rule_owners (
+    code_owner_rule_id BIGINT NOT NULL REFERENCES code_owner_rules(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (code_owner_rule_id, user_id)
+);
+
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS changed_paths TEXT;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE prs DROP COLUMN IF EXISTS changed_paths;
+DROP TABLE IF EXISTS code_owner_rule_owners;
+DROP TABLE IF EXISTS code_owner_rules;
+-- +goose StatementEnd
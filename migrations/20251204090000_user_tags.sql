@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- навыки пользователей и требуемые навыки ревьюверов PR, JSON-массивы строк
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tags TEXT;
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS required_tags TEXT;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE prs DROP COLUMN IF EXISTS required_tags;
+ALTER TABLE users DROP COLUMN IF EXISTS tags;
+-- +goose StatementEnd
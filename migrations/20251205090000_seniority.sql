@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- уровень старшинства пользователей и правило политики о числе старших ревьюверов
+ALTER TABLE users ADD COLUMN IF NOT EXISTS seniority VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS senior_reviewers INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS seniority_level VARCHAR(50) NOT NULL DEFAULT 'senior';
+ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS seniority_action VARCHAR(50) NOT NULL DEFAULT 'fail';
+ALTER TABLE prs ADD COLUMN IF NOT EXISTS policy_unsatisfied BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE prs DROP COLUMN IF EXISTS policy_unsatisfied;
+ALTER TABLE team_policies DROP COLUMN IF EXISTS seniority_action;
+ALTER TABLE team_policies DROP COLUMN IF EXISTS seniority_level;
+ALTER TABLE team_policies DROP COLUMN IF EXISTS senior_reviewers;
+ALTER TABLE users DROP COLUMN IF EXISTS seniority;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- история назначений ревьюверов для учета повторяющихся пар автор-ревьювер
+CREATE TABLE IF NOT EXISTS review_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    pr_id VARCHAR(255) NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    reviewer_id VARCHAR(255) NOT NULL,
+    team_id VARCHAR(255),
+    assigned_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_review_assignments_pr_id ON review_assignments(pr_id);
+CREATE INDEX IF NOT EXISTS idx_review_assignments_pair ON review_assignments(author_id, reviewer_id);
+CREATE INDEX IF NOT EXISTS idx_review_assignments_team_id ON review_assignments(team_id);
+CREATE INDEX IF NOT EXISTS idx_review_assignments_assigned_at ON review_assignments(assigned_at);
+
+-- текущие назначения переносятся в историю
+INSERT INTO review_assignments (pr_id, author_id, reviewer_id, team_id, assigned_at)
+SELECT prs.id, prs.author_id, pr_reviewers.user_id, prs.team_id, COALESCE(prs.created_at, now())
+FROM pr_reviewers
+JOIN prs ON prs.id = pr_reviewers.pr_id;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS review_assignments;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- временное исключение пользователя из автоназначения ревьюверов до заданного момента
+ALTER TABLE users ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMPTZ;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS snoozed_until;
+-- +goose StatementEnd
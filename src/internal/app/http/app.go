@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +23,8 @@ import (
 type App struct {
 	port       int
 	httpServer *http.Server
+	sweeper    *prs.Sweeper
+	absences   *users.AbsenceScheduler
 }
 
 func New(
@@ -45,30 +49,81 @@ func New(
 		panic(err)
 	}
 
+	uow := sql.NewUnitOfWork(repo)
+
+	// Фиксированное зерно делает назначения ревьюверов воспроизводимыми, без него зерно берется из времени.
+	// Некорректное зерно не заменяется молча, иначе назначения перестанут повторяться
+	seed := time.Now().UnixNano()
+	if rawSeed := strings.TrimSpace(os.Getenv("REVIEWER_RANDOM_SEED")); rawSeed != "" {
+		seed, err = strconv.ParseInt(rawSeed, 10, 64)
+		if err != nil {
+			panic(fmt.Errorf("invalid REVIEWER_RANDOM_SEED %q: %w", rawSeed, err))
+		}
+	}
+	rnd := prs.NewRand(seed)
+	clock := prs.SystemClock{}
+
+	prsRepo := prs.NewRepo(repo)
+	prsService := prs.RegisterService(prsRepo, uow, rnd, clock)
+	prsController := prs.RegisterController(prsService, os.Getenv("ADMIN_TOKEN"))
+
+	sweepInterval, err := time.ParseDuration(os.Getenv("REVIEWER_SWEEP_INTERVAL"))
+	if err != nil || sweepInterval <= 0 {
+		sweepInterval = time.Minute
+	}
+	sweeper := prs.NewSweeper(prsService, sweepInterval)
+
+	router.Handle(http.MethodPost, "/pullRequest/create", prsController.Create)
+	router.Handle(http.MethodGet, "/pullRequest/get", prsController.GetByID)
+	router.Handle(http.MethodGet, "/pullRequest/list", prsController.List)
+	router.Handle(http.MethodPost, "/pullRequest/merge", prsController.Merge)
+	router.Handle(http.MethodPost, "/pullRequest/review", prsController.Review)
+	router.Handle(http.MethodPost, "/pullRequest/reassign", prsController.Reassign)
+	router.Handle(http.MethodPost, "/pullRequest/fillReviewers", prsController.FillReviewers)
+	router.Handle(http.MethodPost, "/pullRequest/addReviewer", prsController.AddReviewer)
+	router.Handle(http.MethodPost, "/pullRequest/removeReviewer", prsController.RemoveReviewer)
+	router.Handle(http.MethodPost, "/pullRequest/close", prsController.Close)
+	router.Handle(http.MethodPost, "/pullRequest/reopen", prsController.Reopen)
+	router.Handle(http.MethodPost, "/pullRequest/markReady", prsController.MarkReady)
+
 	teamsRepo := teams.NewRepo(repo)
-	teamsService := teams.RegisterService(teamsRepo)
+	teamsService := teams.RegisterService(teamsRepo, uow, sweeper, clock)
 	teamsController := teams.RegisterController(teamsService)
 
 	router.Handle(http.MethodPost, "/team/add", teamsController.TeamCreate)
 	router.Handle(http.MethodGet, "/team/get", teamsController.TeamGetByName)
 	router.Handle(http.MethodPost, "/team/addUsers", teamsController.AddUsers)
 	router.Handle(http.MethodPost, "/team/deactivateUsers", teamsController.DeactivateUsers)
+	router.Handle(http.MethodPost, "/team/reactivateUsers", teamsController.ReactivateUsers)
+	router.Handle(http.MethodPost, "/team/setReviewerStrategy", teamsController.SetReviewerStrategy)
+	router.Handle(http.MethodPost, "/team/setLead", teamsController.SetLead)
+	router.Handle(http.MethodGet, "/team/policy/get", teamsController.PolicyGet)
+	router.Handle(http.MethodPost, "/team/policy/set", teamsController.PolicySet)
+	router.Handle(http.MethodGet, "/team/codeowners/get", teamsController.CodeOwnersGet)
+	router.Handle(http.MethodPost, "/team/codeowners/set", teamsController.CodeOwnersSet)
 
 	usersRepo := users.NewRepo(repo)
-	usersService := users.RegisterService(usersRepo)
+	usersService := users.RegisterService(usersRepo, sweeper, teamsService, clock)
 	usersController := users.RegisterController(usersService)
 
+	absenceInterval, err := time.ParseDuration(os.Getenv("ABSENCE_CHECK_INTERVAL"))
+	if err != nil || absenceInterval <= 0 {
+		absenceInterval = time.Minute
+	}
+	absences := users.NewAbsenceScheduler(usersService, absenceInterval)
+
 	router.Handle(http.MethodPost, "/users/setIsActive", usersController.SetIsActive)
 	router.Handle(http.MethodGet, "/users/getReview", usersController.GetReview)
-
-	prsRepo := prs.NewRepo(repo)
-	prsService := prs.RegisterService(prsRepo)
-	prsController := prs.RegisterController(prsService)
-
-	router.Handle(http.MethodPost, "/pullRequest/create", prsController.Create)
-	router.Handle(http.MethodGet, "/pullRequest/get", prsController.GetByID)
-	router.Handle(http.MethodPost, "/pullRequest/merge", prsController.Merge)
-	router.Handle(http.MethodPost, "/pullRequest/reassign", prsController.Reassign)
+	router.Handle(http.MethodPost, "/users/setMaxOpenReviews", usersController.SetMaxOpenReviews)
+	router.Handle(http.MethodPost, "/users/setPrimaryTeam", usersController.SetPrimaryTeam)
+	router.Handle(http.MethodPost, "/users/setTags", usersController.SetTags)
+	router.Handle(http.MethodPost, "/users/setSeniority", usersController.SetSeniority)
+	router.Handle(http.MethodPost, "/users/snooze", usersController.Snooze)
+	router.Handle(http.MethodPost, "/users/absence/create", usersController.AbsenceCreate)
+	router.Handle(http.MethodGet, "/users/absence/list", usersController.AbsenceList)
+	router.Handle(http.MethodPost, "/users/absence/update", usersController.AbsenceUpdate)
+	router.Handle(http.MethodPost, "/users/absence/delete", usersController.AbsenceDelete)
+	router.Handle(http.MethodPost, "/users/absence/import", usersController.AbsenceImport)
 
 	statsRepo := stats.NewRepo(repo)
 	statsService := stats.RegisterService(statsRepo)
@@ -77,6 +132,7 @@ func New(
 	router.Handle(http.MethodGet, "/stats/users", statsController.GetUserStats)
 	router.Handle(http.MethodGet, "/stats/overview", statsController.GetOverview)
 	router.Handle(http.MethodGet, "/stats/teams", statsController.GetTeamStats)
+	router.Handle(http.MethodGet, "/stats/pairs", statsController.GetPairStats)
 
 	httpServer := &http.Server{
 		Addr:    address,
@@ -87,6 +143,8 @@ func New(
 	return &App{
 		port:       port,
 		httpServer: httpServer,
+		sweeper:    sweeper,
+		absences:   absences,
 	}
 }
 
@@ -97,6 +155,9 @@ func (a *App) MustRun() {
 }
 
 func (a *App) Run() error {
+	a.sweeper.Start()
+	a.absences.Start()
+
 	if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("http server error: %w", err)
 	}
@@ -105,6 +166,9 @@ func (a *App) Run() error {
 }
 
 func (a *App) Stop() {
+	a.absences.Stop()
+	a.sweeper.Stop()
+
 	if err := a.httpServer.Shutdown(context.Background()); err != nil {
 		fmt.Printf("Stopped")
 	}
@@ -0,0 +1,58 @@
+package prs
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock возвращает текущее время. Подменяется, чтобы назначения и смены статусов были воспроизводимыми
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock - часы, возвращающие системное время
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewRand создает генератор случайных чисел с заданным зерном. При одинаковом зерне и данных
+// выбор ревьюверов повторяется. Генератор безопасен для одновременного использования из нескольких горутин
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// lockedSource защищает источник мьютексом: rand.Source из math/rand не потокобезопасен
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// FixedClock - часы, которые всегда возвращают момент At. Нужны, чтобы повторять назначения в тестах
+type FixedClock struct {
+	At time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.At
+}
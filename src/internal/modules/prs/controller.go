@@ -1,8 +1,11 @@
 package prs
 
 import (
+	"crypto/subtle"
 	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tomatoCoderq/avito_task/src/models"
@@ -10,28 +13,44 @@ import (
 )
 
 type ServiceMethods interface {
-	CreatePR(prID, prName, authorID string) (*models.PR, error)
+	CreatePR(data PRCreationData) (*models.PR, error)
 	GetPRByID(prID string) (*models.PR, error)
-	MergePR(prID string) (*models.PR, error)
-	ReassignReviewer(prID, oldUserID string) (*models.PR, string, error)
+	MergePR(prID string, force bool) (*models.PR, error)
+	ReassignReviewer(prID, oldUserID string, opts ReassignOptions) (*models.PR, *models.PRReassignmentInfo, error)
+	FillReviewers(prID string) (*models.PR, []string, error)
+	ClosePR(prID string) (*models.PR, error)
+	ReopenPR(prID string) (*models.PR, error)
+	MarkReady(prID string) (*models.PR, error)
+	ListPRs(filter PRListFilter, cursor string) ([]models.PR, string, error)
+	SubmitReview(prID, reviewerID, verdict, comment string) (*models.PRReview, error)
+	AddReviewer(prID, userID string) (*models.PR, error)
+	RemoveReviewer(prID, userID string) (*models.PR, error)
 }
 
 type Controller struct {
-	service ServiceMethods
+	service    ServiceMethods
+	adminToken string
 }
 
-func RegisterController(service ServiceMethods) *Controller {
+// RegisterController создает контроллер PR. adminToken разрешает принудительное слияние;
+// пустой токен запрещает его полностью
+func RegisterController(service ServiceMethods, adminToken string) *Controller {
 	return &Controller{
-		service: service,
+		service:    service,
+		adminToken: adminToken,
 	}
 }
 
 // Create создает PR и назначает ревьюверов
 func (c *Controller) Create(ctx *gin.Context) {
 	var req struct {
-		PullRequestID   string `json:"pull_request_id" binding:"required"`
-		PullRequestName string `json:"pull_request_name" binding:"required"`
-		AuthorID        string `json:"author_id" binding:"required"`
+		PullRequestID   string   `json:"pull_request_id" binding:"required"`
+		PullRequestName string   `json:"pull_request_name" binding:"required"`
+		AuthorID        string   `json:"author_id" binding:"required"`
+		TeamName        string   `json:"team_name"`
+		ChangedPaths    []string `json:"changed_paths"`
+		RequiredTags    []string `json:"required_tags"`
+		Draft           bool     `json:"draft"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -44,7 +63,15 @@ func (c *Controller) Create(ctx *gin.Context) {
 		return
 	}
 
-	pr, err := c.service.CreatePR(req.PullRequestID, req.PullRequestName, req.AuthorID)
+	pr, err := c.service.CreatePR(PRCreationData{
+		PRID:         req.PullRequestID,
+		Name:         req.PullRequestName,
+		AuthorID:     req.AuthorID,
+		TeamName:     req.TeamName,
+		ChangedPaths: req.ChangedPaths,
+		RequiredTags: req.RequiredTags,
+		Draft:        req.Draft,
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "author has no team") {
 			ctx.JSON(404, gin.H{
@@ -56,6 +83,33 @@ func (c *Controller) Create(ctx *gin.Context) {
 			return
 		}
 
+		if strings.HasPrefix(err.Error(), "NOT_TEAM_MEMBER") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "NOT_TEAM_MEMBER",
+					"message": "author is not a member of the team",
+				},
+			})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "POLICY_UNSATISFIED") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "POLICY_UNSATISFIED",
+					"message": strings.TrimPrefix(err.Error(), "POLICY_UNSATISFIED: "),
+				},
+			})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "ALL_AT_CAPACITY") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "ALL_AT_CAPACITY",
+					"message": "all candidate reviewers are at capacity",
+				},
+			})
+			return
+		}
 		if strings.Contains(err.Error(), "duplicate") ||
 			strings.Contains(err.Error(), "already exists") ||
 			strings.Contains(err.Error(), "UNIQUE constraint failed") ||
@@ -85,19 +139,25 @@ func (c *Controller) Create(ctx *gin.Context) {
 
 	ctx.JSON(201, gin.H{
 		"pr": gin.H{
-			"pull_request_id":    pr.ID,
-			"pull_request_name":  pr.Name,
-			"author_id":          pr.AuthorID,
-			"status":             pr.Status,
-			"assigned_reviewers": reviewerIDs,
+			"pull_request_id":     pr.ID,
+			"pull_request_name":   pr.Name,
+			"author_id":           pr.AuthorID,
+			"team_id":             pr.TeamID,
+			"status":              pr.Status,
+			"assigned_reviewers":  reviewerIDs,
+			"need_more_reviewers": pr.NeedMoreReviewers,
+			"required_tags":       pr.RequiredTags,
+			"policy_unsatisfied":  pr.PolicyUnsatisfied,
 		},
+		"warnings": prWarnings(pr),
 	})
 }
 
-// Merge помечает PR как MERGED
+// Merge помечает PR как MERGED. Флаг force пропускает проверку одобрений и доступен только администраторам
 func (c *Controller) Merge(ctx *gin.Context) {
 	var req struct {
 		PullRequestID string `json:"pull_request_id" binding:"required"`
+		Force         bool   `json:"force"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -110,7 +170,17 @@ func (c *Controller) Merge(ctx *gin.Context) {
 		return
 	}
 
-	pr, err := c.service.MergePR(req.PullRequestID)
+	if req.Force && !c.isAdmin(ctx) {
+		ctx.JSON(403, gin.H{
+			"error": gin.H{
+				"code":    "FORBIDDEN",
+				"message": "force merge is allowed only for admins",
+			},
+		})
+		return
+	}
+
+	pr, err := c.service.MergePR(req.PullRequestID, req.Force)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		ctx.JSON(404, gin.H{
 			"error": gin.H{
@@ -120,6 +190,25 @@ func (c *Controller) Merge(ctx *gin.Context) {
 		})
 		return
 	}
+	var transitionErr *models.TransitionError
+	if errors.As(err, &transitionErr) {
+		ctx.JSON(409, gin.H{
+			"error": gin.H{
+				"code":    transitionErr.Code(),
+				"message": transitionErr.Error(),
+			},
+		})
+		return
+	}
+	if err != nil && strings.Contains(err.Error(), "NOT_APPROVED") {
+		ctx.JSON(409, gin.H{
+			"error": gin.H{
+				"code":    "NOT_APPROVED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{
 			"error": gin.H{
@@ -143,6 +232,8 @@ func (c *Controller) Merge(ctx *gin.Context) {
 			"author_id":          pr.AuthorID,
 			"status":             pr.Status,
 			"assigned_reviewers": reviewerIDs,
+			"created_at":         pr.CreatedAt,
+			"merged_at":          pr.MergedAt,
 		},
 	})
 }
@@ -152,9 +243,11 @@ func (c *Controller) Reassign(ctx *gin.Context) {
 	var req struct {
 		PullRequestID string `json:"pull_request_id" binding:"required"`
 		OldUserID     string `json:"old_reviewer_id" binding:"required"`
+		NewUserID     string `json:"new_reviewer_id"`
+		Strategy      string `json:"strategy"`
 	}
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.Strategy != "" && !models.IsValidReviewerStrategy(req.Strategy)) {
 		ctx.JSON(400, gin.H{
 			"error": gin.H{
 				"code":    "INVALID_REQUEST",
@@ -164,7 +257,10 @@ func (c *Controller) Reassign(ctx *gin.Context) {
 		return
 	}
 
-	pr, replacedBy, err := c.service.ReassignReviewer(req.PullRequestID, req.OldUserID)
+	pr, info, err := c.service.ReassignReviewer(req.PullRequestID, req.OldUserID, ReassignOptions{
+		NewReviewerID: req.NewUserID,
+		Strategy:      req.Strategy,
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			ctx.JSON(404, gin.H{
@@ -186,6 +282,15 @@ func (c *Controller) Reassign(ctx *gin.Context) {
 			})
 			return
 		}
+		if strings.Contains(errMsg, "PR_NOT_OPEN") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "PR_NOT_OPEN",
+					"message": "reviewers can only be reassigned on open PR",
+				},
+			})
+			return
+		}
 		if strings.Contains(errMsg, "NOT_ASSIGNED") {
 			ctx.JSON(409, gin.H{
 				"error": gin.H{
@@ -195,11 +300,38 @@ func (c *Controller) Reassign(ctx *gin.Context) {
 			})
 			return
 		}
+		if strings.HasPrefix(errMsg, "NOT_ELIGIBLE") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "NOT_ELIGIBLE",
+					"message": strings.TrimPrefix(errMsg, "NOT_ELIGIBLE: "),
+				},
+			})
+			return
+		}
+		if strings.HasPrefix(errMsg, "POLICY_UNSATISFIED") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "POLICY_UNSATISFIED",
+					"message": strings.TrimPrefix(errMsg, "POLICY_UNSATISFIED: "),
+				},
+			})
+			return
+		}
+		if strings.HasPrefix(errMsg, "ALL_AT_CAPACITY") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "ALL_AT_CAPACITY",
+					"message": "all replacement candidates are at capacity",
+				},
+			})
+			return
+		}
 		if strings.Contains(errMsg, "NO_CANDIDATE") {
 			ctx.JSON(409, gin.H{
 				"error": gin.H{
 					"code":    "NO_CANDIDATE",
-					"message": "no active replacement candidate in team",
+					"message": "no active replacement candidate in team or fallback teams",
 				},
 			})
 			return
@@ -227,7 +359,8 @@ func (c *Controller) Reassign(ctx *gin.Context) {
 			"status":             pr.Status,
 			"assigned_reviewers": reviewerIDs,
 		},
-		"replaced_by": replacedBy,
+		"replaced_by":      info.ToReviewer,
+		"replacement_team": info.ReplacementTeam,
 	})
 }
 
@@ -270,10 +403,439 @@ func (c *Controller) GetByID(ctx *gin.Context) {
 	}
 
 	ctx.JSON(200, gin.H{
-		"pull_request_id":    pr.ID,
-		"pull_request_name":  pr.Name,
-		"author_id":          pr.AuthorID,
-		"status":             pr.Status,
-		"assigned_reviewers": reviewerIDs,
+		"pull_request_id":     pr.ID,
+		"pull_request_name":   pr.Name,
+		"author_id":           pr.AuthorID,
+		"team_id":             pr.TeamID,
+		"status":              pr.Status,
+		"assigned_reviewers":  reviewerIDs,
+		"need_more_reviewers": pr.NeedMoreReviewers,
+		"author_inactive":     pr.AuthorInactive,
+		"created_at":          pr.CreatedAt,
+		"updated_at":          pr.UpdatedAt,
+		"merged_at":           pr.MergedAt,
 	})
 }
+
+// FillReviewers доназначает ревьюверов в PR до числа, требуемого политикой команды
+func (c *Controller) FillReviewers(ctx *gin.Context) {
+	var req struct {
+		PullRequestID string `json:"pull_request_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	pr, addedIDs, err := c.service.FillReviewers(req.PullRequestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "author has no team") {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "PR or team not found",
+				},
+			})
+			return
+		}
+		if strings.Contains(err.Error(), "PR_NOT_OPEN") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "PR_NOT_OPEN",
+					"message": "reviewers can only be added to open PR",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fill reviewers",
+			},
+		})
+		return
+	}
+
+	reviewerIDs := make([]string, 0, len(pr.Reviewers))
+	for _, reviewer := range pr.Reviewers {
+		reviewerIDs = append(reviewerIDs, reviewer.ID)
+	}
+
+	ctx.JSON(200, gin.H{
+		"pr": gin.H{
+			"pull_request_id":     pr.ID,
+			"pull_request_name":   pr.Name,
+			"author_id":           pr.AuthorID,
+			"status":              pr.Status,
+			"assigned_reviewers":  reviewerIDs,
+			"need_more_reviewers": pr.NeedMoreReviewers,
+		},
+		"added_reviewers": addedIDs,
+		"warnings":        prWarnings(pr),
+	})
+}
+
+// List возвращает список PR с фильтрами и курсорной пагинацией
+func (c *Controller) List(ctx *gin.Context) {
+	filter := PRListFilter{
+		Status:     ctx.Query("status"),
+		AuthorID:   ctx.Query("author_id"),
+		ReviewerID: ctx.Query("reviewer_id"),
+		TeamName:   ctx.Query("team_name"),
+		SortBy:     ctx.Query("sort_by"),
+		Order:      ctx.Query("order"),
+	}
+
+	invalid := func(message string) {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": message,
+			},
+		})
+	}
+
+	if value := ctx.Query("need_more_reviewers"); value != "" {
+		needMore, err := strconv.ParseBool(value)
+		if err != nil {
+			invalid("need_more_reviewers must be a boolean")
+			return
+		}
+		filter.NeedMoreReviewers = &needMore
+	}
+
+	if value := ctx.Query("created_from"); value != "" {
+		createdFrom, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			invalid("created_from must be an RFC 3339 timestamp")
+			return
+		}
+		filter.CreatedFrom = &createdFrom
+	}
+
+	if value := ctx.Query("created_to"); value != "" {
+		createdTo, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			invalid("created_to must be an RFC 3339 timestamp")
+			return
+		}
+		filter.CreatedTo = &createdTo
+	}
+
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			invalid("limit must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	prs, nextCursor, err := c.service.ListPRs(filter, ctx.Query("cursor"))
+	if err != nil {
+		if strings.Contains(err.Error(), "INVALID_REQUEST") || strings.Contains(err.Error(), "INVALID_CURSOR") {
+			invalid(err.Error())
+			return
+		}
+
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to list PRs",
+			},
+		})
+		return
+	}
+
+	pullRequests := make([]gin.H, 0, len(prs))
+	for i := range prs {
+		pullRequests = append(pullRequests, prResponse(&prs[i]))
+	}
+
+	ctx.JSON(200, gin.H{
+		"pull_requests": pullRequests,
+		"next_cursor":   nextCursor,
+	})
+}
+
+// Review сохраняет вердикт ревьювера по PR
+func (c *Controller) Review(ctx *gin.Context) {
+	var req struct {
+		PullRequestID string `json:"pull_request_id" binding:"required"`
+		ReviewerID    string `json:"reviewer_id" binding:"required"`
+		Verdict       string `json:"verdict" binding:"required"`
+		Comment       string `json:"comment"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || !models.IsValidVerdict(req.Verdict) {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	review, err := c.service.SubmitReview(req.PullRequestID, req.ReviewerID, req.Verdict, req.Comment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "PR not found",
+				},
+			})
+			return
+		}
+		if strings.Contains(err.Error(), "PR_NOT_OPEN") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "PR_NOT_OPEN",
+					"message": "reviews can only be submitted on open PR",
+				},
+			})
+			return
+		}
+		if strings.Contains(err.Error(), "NOT_ASSIGNED") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "NOT_ASSIGNED",
+					"message": "reviewer is not assigned to this PR",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to submit review",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(201, gin.H{
+		"review": gin.H{
+			"pull_request_id": review.PRID,
+			"reviewer_id":     review.ReviewerID,
+			"verdict":         review.Verdict,
+			"comment":         review.Comment,
+			"created_at":      review.CreatedAt,
+		},
+	})
+}
+
+// reviewerConflicts - коды ошибок ручного изменения ревьюверов, возвращаемые с 409
+var reviewerConflicts = map[string]string{
+	"PR_MERGED":            "cannot change reviewers on merged PR",
+	"USER_INACTIVE":        "reviewer is not active",
+	"AUTHOR_CANNOT_REVIEW": "author cannot review own PR",
+	"ALREADY_ASSIGNED":     "reviewer is already assigned to this PR",
+	"NOT_ASSIGNED":         "reviewer is not assigned to this PR",
+}
+
+// AddReviewer вручную назначает ревьювера в PR
+func (c *Controller) AddReviewer(ctx *gin.Context) {
+	c.changeReviewer(ctx, c.service.AddReviewer)
+}
+
+// RemoveReviewer снимает ревьювера с PR
+func (c *Controller) RemoveReviewer(ctx *gin.Context) {
+	c.changeReviewer(ctx, c.service.RemoveReviewer)
+}
+
+// changeReviewer обрабатывает запрос ручного изменения состава ревьюверов
+func (c *Controller) changeReviewer(ctx *gin.Context, change func(prID, userID string) (*models.PR, error)) {
+	var req struct {
+		PullRequestID string `json:"pull_request_id" binding:"required"`
+		ReviewerID    string `json:"reviewer_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	pr, err := change(req.PullRequestID, req.ReviewerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "author has no team") {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "PR or user not found",
+				},
+			})
+			return
+		}
+
+		for code, message := range reviewerConflicts {
+			if strings.HasPrefix(err.Error(), code+":") {
+				ctx.JSON(409, gin.H{
+					"error": gin.H{
+						"code":    code,
+						"message": message,
+					},
+				})
+				return
+			}
+		}
+
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to change reviewers",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"pr": prResponse(pr),
+	})
+}
+
+// Close закрывает PR без слияния
+func (c *Controller) Close(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.ClosePR)
+}
+
+// Reopen повторно открывает закрытый PR
+func (c *Controller) Reopen(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.ReopenPR)
+}
+
+// MarkReady переводит черновик в OPEN и назначает ревьюверов
+func (c *Controller) MarkReady(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.MarkReady)
+}
+
+// changeStatus обрабатывает запрос смены статуса PR
+func (c *Controller) changeStatus(ctx *gin.Context, change func(prID string) (*models.PR, error)) {
+	var req struct {
+		PullRequestID string `json:"pull_request_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	pr, err := change(req.PullRequestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "PR not found",
+			},
+		})
+		return
+	}
+	var transitionErr *models.TransitionError
+	if errors.As(err, &transitionErr) {
+		ctx.JSON(409, gin.H{
+			"error": gin.H{
+				"code":    transitionErr.Code(),
+				"message": transitionErr.Error(),
+			},
+		})
+		return
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "POLICY_UNSATISFIED") {
+		ctx.JSON(409, gin.H{
+			"error": gin.H{
+				"code":    "POLICY_UNSATISFIED",
+				"message": strings.TrimPrefix(err.Error(), "POLICY_UNSATISFIED: "),
+			},
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to change PR status",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"pr":       prResponse(pr),
+		"warnings": prWarnings(pr),
+	})
+}
+
+// prWarnings формирует предупреждения о назначении ревьюверов PR, которые не мешают выполнить запрос
+func prWarnings(pr *models.PR) []gin.H {
+	warnings := []gin.H{}
+	if pr.Status != models.PRStatusOpen {
+		return warnings
+	}
+
+	if pr.PolicyUnsatisfied {
+		warnings = append(warnings, gin.H{
+			"code":    "POLICY_UNSATISFIED",
+			"message": "assigned reviewers do not satisfy the team seniority rule",
+		})
+	}
+	if uncovered := pr.UncoveredTags(); len(uncovered) > 0 {
+		warnings = append(warnings, gin.H{
+			"code":    "UNCOVERED_TAGS",
+			"message": "no assigned reviewer covers tags: " + strings.Join(uncovered, ", "),
+			"tags":    uncovered,
+		})
+	}
+
+	return warnings
+}
+
+// prResponse формирует представление PR для ответа API
+func prResponse(pr *models.PR) gin.H {
+	reviewerIDs := make([]string, 0, len(pr.Reviewers))
+	for _, reviewer := range pr.Reviewers {
+		reviewerIDs = append(reviewerIDs, reviewer.ID)
+	}
+
+	return gin.H{
+		"pull_request_id":     pr.ID,
+		"pull_request_name":   pr.Name,
+		"author_id":           pr.AuthorID,
+		"team_id":             pr.TeamID,
+		"status":              pr.Status,
+		"assigned_reviewers":  reviewerIDs,
+		"need_more_reviewers": pr.NeedMoreReviewers,
+		"author_inactive":     pr.AuthorInactive,
+		"required_tags":       pr.RequiredTags,
+		"policy_unsatisfied":  pr.PolicyUnsatisfied,
+		"created_at":          pr.CreatedAt,
+		"updated_at":          pr.UpdatedAt,
+		"merged_at":           pr.MergedAt,
+	}
+}
+
+// isAdmin проверяет административный токен запроса
+func (c *Controller) isAdmin(ctx *gin.Context) bool {
+	if c.adminToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(ctx.GetHeader("X-Admin-Token")), []byte(c.adminToken)) == 1
+}
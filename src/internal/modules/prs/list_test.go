@@ -0,0 +1,192 @@
+package prs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomatoCoderq/avito_task/src/models"
+)
+
+// listRepo повторяет в памяти сортировку и keyset-условие ListPRs из репозитория:
+// сравнение идет по паре (колонка сортировки, id)
+type listRepo struct {
+	RepositoryMethods
+
+	prs []models.PR
+}
+
+func (r *listRepo) ListPRs(filter PRListFilter) ([]models.PR, error) {
+	key := func(pr models.PR) time.Time {
+		if filter.SortBy == SortByUpdatedAt {
+			return pr.UpdatedAt
+		}
+		return pr.CreatedAt
+	}
+	// less сравнивает записи в порядке возрастания
+	less := func(a models.PR, b models.PR) bool {
+		if filter.SortBy != SortByID && !key(a).Equal(key(b)) {
+			return key(a).Before(key(b))
+		}
+		return a.ID < b.ID
+	}
+
+	sorted := append([]models.PR(nil), r.prs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if filter.Order == OrderDesc {
+			return less(sorted[j], sorted[i])
+		}
+		return less(sorted[i], sorted[j])
+	})
+
+	var after *models.PR
+	if filter.Cursor != nil {
+		after = &models.PR{ID: filter.Cursor.ID}
+		if filter.SortBy != SortByID {
+			value, err := time.Parse(time.RFC3339Nano, filter.Cursor.Value)
+			if err != nil {
+				return nil, err
+			}
+			after.CreatedAt, after.UpdatedAt = value, value
+		}
+	}
+
+	result := []models.PR{}
+	for _, pr := range sorted {
+		if after != nil {
+			if filter.Order == OrderDesc && !less(pr, *after) {
+				continue
+			}
+			if filter.Order == OrderAsc && !less(*after, pr) {
+				continue
+			}
+		}
+		if len(result) == filter.Limit {
+			break
+		}
+		result = append(result, pr)
+	}
+	return result, nil
+}
+
+// newListRepo создает PR, у которых по три подряд совпадает время создания
+func newListRepo() *listRepo {
+	base := time.Date(2025, 12, 1, 9, 0, 0, 123456789, time.UTC)
+	repo := &listRepo{}
+	for i := 1; i <= 9; i++ {
+		at := base.Add(time.Duration((i-1)/3) * time.Minute)
+		repo.prs = append(repo.prs, models.PR{
+			ID:        fmt.Sprintf("pr-%02d", i),
+			CreatedAt: at,
+			UpdatedAt: at,
+		})
+	}
+	return repo
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []PRListCursor{
+		{SortBy: SortByCreatedAt, Order: OrderDesc, Value: "2025-12-01T09:00:00.123456789Z", ID: "pr-1"},
+		{SortBy: SortByUpdatedAt, Order: OrderAsc, Value: "2025-12-01T12:00:00+03:00", ID: "pr/2"},
+		{SortBy: SortByID, Order: OrderAsc, ID: "pr-3"},
+	}
+
+	for _, cursor := range cursors {
+		encoded, err := encodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("encodeCursor: %v", err)
+		}
+		decoded, err := decodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q): %v", encoded, err)
+		}
+		if !reflect.DeepEqual(*decoded, cursor) {
+			t.Errorf("got %+v, want %+v", *decoded, cursor)
+		}
+	}
+}
+
+func TestListPRsPagesWithoutGapsOrDuplicates(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		order  string
+		want   []string
+	}{
+		{SortByCreatedAt, OrderAsc, []string{"pr-01", "pr-02", "pr-03", "pr-04", "pr-05", "pr-06", "pr-07", "pr-08", "pr-09"}},
+		{SortByCreatedAt, OrderDesc, []string{"pr-09", "pr-08", "pr-07", "pr-06", "pr-05", "pr-04", "pr-03", "pr-02", "pr-01"}},
+		{SortByUpdatedAt, OrderAsc, []string{"pr-01", "pr-02", "pr-03", "pr-04", "pr-05", "pr-06", "pr-07", "pr-08", "pr-09"}},
+		{SortByID, OrderDesc, []string{"pr-09", "pr-08", "pr-07", "pr-06", "pr-05", "pr-04", "pr-03", "pr-02", "pr-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy+" "+tt.order, func(t *testing.T) {
+			service := RegisterService(newListRepo(), nil, NewRand(1), FixedClock{})
+
+			// Страница из двух записей разрезает группы с одинаковым временем создания
+			got := []string{}
+			cursor := ""
+			for page := 0; ; page++ {
+				if page > len(tt.want) {
+					t.Fatalf("pagination did not stop, got %v", got)
+				}
+				prs, next, err := service.ListPRs(PRListFilter{SortBy: tt.sortBy, Order: tt.order, Limit: 2}, cursor)
+				if err != nil {
+					t.Fatalf("ListPRs: %v", err)
+				}
+				if len(prs) > 2 {
+					t.Fatalf("got %d PRs on a page, want at most 2", len(prs))
+				}
+				for _, pr := range prs {
+					got = append(got, pr.ID)
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPRsRejectsInvalidCursor(t *testing.T) {
+	encode := func(cursor PRListCursor) string {
+		encoded, err := encodeCursor(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	valid := PRListCursor{SortBy: SortByCreatedAt, Order: OrderDesc, Value: "2025-12-01T09:00:00Z", ID: "pr-01"}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"base64 of non-json", base64.RawURLEncoding.EncodeToString([]byte("pr-01"))},
+		{"truncated", encode(valid)[:10]},
+		{"other sort_by", encode(PRListCursor{SortBy: SortByUpdatedAt, Order: OrderDesc, Value: valid.Value, ID: "pr-01"})},
+		{"other order", encode(PRListCursor{SortBy: SortByCreatedAt, Order: OrderAsc, Value: valid.Value, ID: "pr-01"})},
+		{"malformed value", encode(PRListCursor{SortBy: SortByCreatedAt, Order: OrderDesc, Value: "yesterday", ID: "pr-01"})},
+		{"missing value", encode(PRListCursor{SortBy: SortByCreatedAt, Order: OrderDesc, ID: "pr-01"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := RegisterService(newListRepo(), nil, NewRand(1), FixedClock{})
+
+			_, _, err := service.ListPRs(PRListFilter{SortBy: SortByCreatedAt, Order: OrderDesc}, tt.cursor)
+			if err == nil || !strings.HasPrefix(err.Error(), "INVALID_CURSOR") {
+				t.Errorf("got error %v, want INVALID_CURSOR", err)
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package prs
 
 import (
 	"errors"
+	"time"
 
+	"github.com/tomatoCoderq/avito_task/src/internal/storage/sql"
 	"github.com/tomatoCoderq/avito_task/src/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repo struct {
@@ -17,12 +20,26 @@ func NewRepo(db *gorm.DB) *Repo {
 	}
 }
 
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *Repo) WithTx(tx *gorm.DB) RepositoryMethods {
+	return &Repo{
+		db: tx,
+	}
+}
+
 func (r *Repo) CreatePR(pr *models.PR) (*models.PR, error) {
 	if err := r.db.Create(pr).Error; err != nil {
 		return nil, err
 	}
-	
-	
+
+	reviewerIDs := make([]string, len(pr.Reviewers))
+	for i, reviewer := range pr.Reviewers {
+		reviewerIDs[i] = reviewer.ID
+	}
+	if err := sql.RecordAssignments(r.db, pr.ID, reviewerIDs, pr.CreatedAt); err != nil {
+		return nil, err
+	}
+
 	if err := r.db.Preload("Author").Preload("Reviewers").First(pr, "id = ?", pr.ID).Error; err != nil {
 		return nil, err
 	}
@@ -39,27 +56,53 @@ func (r *Repo) GetPRByID(prID string) (*models.PR, error) {
 	return &pr, nil
 }
 
-func (r *Repo) MergePR(prID string) (*models.PR, error) {
+// GetPRForUpdate получает PR и блокирует его строку до конца транзакции,
+// чтобы параллельные изменения ревьюверов и статуса выполнялись по очереди
+func (r *Repo) GetPRForUpdate(prID string) (*models.PR, error) {
 	var pr models.PR
-	if err := r.db.First(&pr, "id = ?", prID).Error; err != nil {
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pr, "id = ?", prID).Error; err != nil {
 		return nil, err
 	}
 
-	pr.Status = "MERGED"
-	
-	if err := r.db.Save(&pr).Error; err != nil {
-		return nil, err
-	}
+	return r.GetPRByID(prID)
+}
 
-	
-	if err := r.db.Preload("Author").Preload("Reviewers").First(&pr, "id = ?", prID).Error; err != nil {
+// UpdateStatus переводит PR в новый статус с проверкой допустимости перехода.
+// Строка PR блокируется на время операции. Повторный перевод в текущий статус ничего не меняет,
+// поэтому повторное слияние возвращает исходное время merged_at
+func (r *Repo) UpdateStatus(prID string, status string, at time.Time) (*models.PR, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var pr models.PR
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pr, "id = ?", prID).Error; err != nil {
+			return err
+		}
+
+		if pr.Status == status {
+			return nil
+		}
+
+		if err := models.CheckTransition(pr.Status, status); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":     status,
+			"updated_at": at,
+		}
+		if status == models.PRStatusMerged {
+			updates["merged_at"] = at
+		}
+
+		return tx.Model(&pr).Updates(updates).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return &pr, nil
+	return r.GetPRByID(prID)
 }
 
-func (r *Repo) ReassignReviewer(prID string, oldUserID string, newUserID string) (*models.PR, error) {
+func (r *Repo) ReassignReviewer(prID string, oldUserID string, newUserID string, at time.Time) (*models.PR, error) {
 	var pr models.PR
 	if err := r.db.Preload("Reviewers").First(&pr, "id = ?", prID).Error; err != nil {
 		return nil, err
@@ -93,6 +136,10 @@ func (r *Repo) ReassignReviewer(prID string, oldUserID string, newUserID string)
 		return nil, err
 	}
 
+	if err := sql.RecordAssignments(r.db, prID, []string{newUserID}, at); err != nil {
+		return nil, err
+	}
+
 	if err := r.db.Preload("Author").Preload("Reviewers").First(&pr, "id = ?", prID).Error; err != nil {
 		return nil, err
 	}
@@ -109,12 +156,49 @@ func (r *Repo) GetUserByID(userID string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *Repo) GetActiveTeamMembers(teamID string, excludeUserID string) ([]models.User, error) {
+func (r *Repo) GetTeamByID(teamID string) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.First(&team, "id = ?", teamID).Error; err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// GetActiveTeamMembers получает активных участников команды, кроме excludeUserID.
+// Строки пользователей блокируются на чтение до конца транзакции, чтобы их не деактивировали во время назначения
+func (r *Repo) GetActiveTeamMembers(teamID string, excludeUserID string, at time.Time) ([]models.User, error) {
 	var users []models.User
 	
 	if err := r.db.
+		Clauses(clause.Locking{Strength: "SHARE", Table: clause.Table{Name: "users"}}).
 		Joins("JOIN team_users ON team_users.user_id = users.id").
-		Where("team_users.team_id = ? AND users.is_active = ? AND users.id != ?", teamID, true, excludeUserID).
+		Order("users.id").
+		Where("team_users.team_id = ? AND users.is_active = ? AND users.out_of_rotation = ? AND users.id != ?", teamID, true, false, excludeUserID).
+		Where(sql.NotAbsent, at, at).
+		Where(sql.NotSnoozed, at).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetActiveUsersByIDs получает активных пользователей из списка, кроме excludeUserID, с теми же
+// фильтрами и блокировкой, что и GetActiveTeamMembers
+func (r *Repo) GetActiveUsersByIDs(userIDs []string, excludeUserID string, at time.Time) ([]models.User, error) {
+	users := []models.User{}
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.
+		Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("users.id IN ? AND users.id != ?", userIDs, excludeUserID).
+		Where("users.is_active = ? AND users.out_of_rotation = ?", true, false).
+		Where(sql.NotAbsent, at, at).
+		Where(sql.NotSnoozed, at).
+		Order("users.id").
 		Find(&users).Error; err != nil {
 		return nil, err
 	}
@@ -122,3 +206,303 @@ func (r *Repo) GetActiveTeamMembers(teamID string, excludeUserID string) ([]mode
 	return users, nil
 }
 
+// IsAbsent сообщает, идет ли у пользователя период отсутствия в момент at
+func (r *Repo) IsAbsent(userID string, at time.Time) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Absence{}).
+		Where("user_id = ? AND starts_at <= ? AND ends_at > ?", userID, at, at).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetCodeOwnerRules возвращает правила владения кодом команды с владельцами в порядке файла
+func (r *Repo) GetCodeOwnerRules(teamID string) ([]models.CodeOwnerRule, error) {
+	var rules []models.CodeOwnerRule
+	if err := r.db.
+		Preload("Owners").
+		Where("team_id = ?", teamID).
+		Order("position").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// GetTeamPolicy возвращает политику команды или политику по умолчанию, если она не задана
+func (r *Repo) GetTeamPolicy(teamID string) (*models.TeamPolicy, error) {
+	var policy models.TeamPolicy
+	err := r.db.
+		Preload("ExcludedUsers").
+		Preload("FallbackTeams", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("FallbackTeams.FallbackTeam").
+		First(&policy, "team_id = ?", teamID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultTeamPolicy(teamID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// GetActiveUsersOutsideTeam получает активных пользователей, не состоящих в команде
+func (r *Repo) GetActiveUsersOutsideTeam(teamID string, excludeUserIDs []string, at time.Time) ([]models.User, error) {
+	var users []models.User
+
+	query := r.db.
+		Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("users.is_active = ? AND users.out_of_rotation = ?", true, false).
+		Where(sql.NotAbsent, at, at).
+		Where(sql.NotSnoozed, at).
+		Where("users.id NOT IN (?)", r.db.Table("team_users").Select("user_id").Where("team_id = ?", teamID))
+
+	if len(excludeUserIDs) > 0 {
+		query = query.Where("users.id NOT IN ?", excludeUserIDs)
+	}
+
+	if err := query.Preload("Teams").Order("users.id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CountRecentPairings считает, сколько из последних window PR автора ревьюил каждый из пользователей.
+// Учитываются все назначения из истории, в том числе снятые позже
+func (r *Repo) CountRecentPairings(authorID string, userIDs []string, window int) (map[string]int, error) {
+	counts := make(map[string]int, len(userIDs))
+	if len(userIDs) == 0 || window <= 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ReviewerID string
+		Pairings   int
+	}
+	if err := r.db.Raw(`
+		SELECT reviewer_id, COUNT(DISTINCT pr_id) AS pairings
+		FROM review_assignments
+		WHERE reviewer_id IN ? AND pr_id IN (
+			SELECT id FROM prs WHERE author_id = ? ORDER BY created_at DESC, id DESC LIMIT ?)
+		GROUP BY reviewer_id`, userIDs, authorID, window).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ReviewerID] = row.Pairings
+	}
+	return counts, nil
+}
+
+// LockUsers блокирует строки пользователей на чтение до конца транзакции
+func (r *Repo) LockUsers(userIDs []string) error {
+	var lockedIDs []string
+	return r.db.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("id IN ?", userIDs).
+		Order("id").
+		Pluck("id", &lockedIDs).Error
+}
+
+// AddReviewers добавляет ревьюверов в PR и обновляет флаг NeedMoreReviewers
+func (r *Repo) AddReviewers(prID string, userIDs []string, needMoreReviewers bool, at time.Time) (*models.PR, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, userID := range userIDs {
+			if err := tx.Exec(
+				"INSERT INTO pr_reviewers (pr_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+				prID, userID,
+			).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := sql.RecordAssignments(tx, prID, userIDs, at); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.PR{}).
+			Where("id = ?", prID).
+			Update("need_more_reviewers", needMoreReviewers).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPRByID(prID)
+}
+
+// SetPolicyUnsatisfied обновляет флаг невыполненного правила старшинства ревьюверов PR
+func (r *Repo) SetPolicyUnsatisfied(prID string, unsatisfied bool) (*models.PR, error) {
+	if err := r.db.Model(&models.PR{}).
+		Where("id = ?", prID).
+		Update("policy_unsatisfied", unsatisfied).Error; err != nil {
+		return nil, err
+	}
+
+	return r.GetPRByID(prID)
+}
+
+// RemoveReviewer снимает ревьювера с PR и обновляет флаг NeedMoreReviewers
+func (r *Repo) RemoveReviewer(prID string, userID string, needMoreReviewers bool) (*models.PR, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(
+			"DELETE FROM pr_reviewers WHERE pr_id = ? AND user_id = ?",
+			prID, userID,
+		).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.PR{}).
+			Where("id = ?", prID).
+			Update("need_more_reviewers", needMoreReviewers).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPRByID(prID)
+}
+
+// GetUnderstaffedPRIDs возвращает ID открытых PR, которым не хватает ревьюверов
+func (r *Repo) GetUnderstaffedPRIDs() ([]string, error) {
+	var prIDs []string
+	if err := r.db.Model(&models.PR{}).
+		Where("status = ? AND need_more_reviewers = ?", models.PRStatusOpen, true).
+		Pluck("id", &prIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return prIDs, nil
+}
+
+// ListPRs возвращает страницу PR по фильтру с keyset-пагинацией.
+// Ревьюверы подгружаются одним запросом для всей страницы и содержат только ID
+func (r *Repo) ListPRs(filter PRListFilter) ([]models.PR, error) {
+	query := r.db.Model(&models.PR{})
+
+	if filter.Status != "" {
+		query = query.Where("prs.status = ?", filter.Status)
+	}
+	if filter.AuthorID != "" {
+		query = query.Where("prs.author_id = ?", filter.AuthorID)
+	}
+	if filter.ReviewerID != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM pr_reviewers WHERE pr_reviewers.pr_id = prs.id AND pr_reviewers.user_id = ?)", filter.ReviewerID)
+	}
+	if filter.TeamName != "" {
+		query = query.Where("prs.team_id IN (SELECT teams.id FROM teams WHERE teams.name = ?)", filter.TeamName)
+	}
+	if filter.NeedMoreReviewers != nil {
+		query = query.Where("prs.need_more_reviewers = ?", *filter.NeedMoreReviewers)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("prs.created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("prs.created_at < ?", *filter.CreatedTo)
+	}
+
+	// Направление и колонка сортировки проверены сервисом, поэтому их можно подставлять в SQL
+	op := ">"
+	if filter.Order == OrderDesc {
+		op = "<"
+	}
+	column := "prs." + filter.SortBy
+
+	if filter.Cursor != nil {
+		if filter.SortBy == SortByID {
+			query = query.Where("prs.id "+op+" ?", filter.Cursor.ID)
+		} else {
+			value, err := time.Parse(time.RFC3339Nano, filter.Cursor.Value)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("("+column+", prs.id) "+op+" (?, ?)", value, filter.Cursor.ID)
+		}
+	}
+
+	if filter.SortBy != SortByID {
+		query = query.Order(column + " " + filter.Order)
+	}
+	query = query.Order("prs.id " + filter.Order)
+
+	var prs []models.PR
+	if err := query.Limit(filter.Limit).Find(&prs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(prs) == 0 {
+		return prs, nil
+	}
+
+	prIDs := make([]string, len(prs))
+	for i, pr := range prs {
+		prIDs[i] = pr.ID
+	}
+
+	var rows []struct {
+		PRID   string
+		UserID string
+	}
+	if err := r.db.Table("pr_reviewers").
+		Select("pr_id, user_id").
+		Where("pr_id IN ?", prIDs).
+		Order("user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	reviewers := make(map[string][]models.User, len(prs))
+	for _, row := range rows {
+		reviewers[row.PRID] = append(reviewers[row.PRID], models.User{ID: row.UserID})
+	}
+	for i := range prs {
+		prs[i].Reviewers = reviewers[prs[i].ID]
+	}
+
+	return prs, nil
+}
+
+// CreateReview сохраняет вердикт ревьювера
+func (r *Repo) CreateReview(review *models.PRReview) (*models.PRReview, error) {
+	if err := r.db.Create(review).Error; err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// GetLatestVerdicts возвращает последний значимый вердикт каждого ревьювера PR.
+// COMMENTED не отменяет ранее поставленный вердикт и не учитывается
+func (r *Repo) GetLatestVerdicts(prID string) (map[string]string, error) {
+	var rows []struct {
+		ReviewerID string
+		Verdict    string
+	}
+
+	if err := r.db.Raw(`
+		SELECT DISTINCT ON (reviewer_id) reviewer_id, verdict
+		FROM pr_reviews
+		WHERE pr_id = ? AND verdict <> ?
+		ORDER BY reviewer_id, created_at DESC, id DESC`, prID, models.VerdictCommented).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	verdicts := make(map[string]string, len(rows))
+	for _, row := range rows {
+		verdicts[row.ReviewerID] = row.Verdict
+	}
+
+	return verdicts, nil
+}
+
+// CountOpenReviews возвращает число открытых PR на ревью у каждого из пользователей
+func (r *Repo) CountOpenReviews(userIDs []string) (map[string]int, error) {
+	return sql.CountOpenReviews(r.db, userIDs)
+}
@@ -0,0 +1,133 @@
+package prs
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/tomatoCoderq/avito_task/src/models"
+)
+
+// ReviewerSelector выбирает до count ревьюверов из списка кандидатов команды
+type ReviewerSelector interface {
+	Select(teamID string, candidates []ReassignmentCandidate, count int) []ReassignmentCandidate
+}
+
+// NewSelectors возвращает все поддерживаемые стратегии, индексированные по имени.
+// Случайные стратегии берут случайность из rnd
+func NewSelectors(rnd *rand.Rand) map[string]ReviewerSelector {
+	return map[string]ReviewerSelector{
+		models.ReviewerStrategyRandom:      &RandomSelector{rnd: rnd},
+		models.ReviewerStrategyRoundRobin:  NewRoundRobinSelector(),
+		models.ReviewerStrategyLeastLoaded: &LeastLoadedSelector{rnd: rnd},
+		models.ReviewerStrategyDiverse:     &DiverseSelector{rnd: rnd},
+	}
+}
+
+// RandomSelector выбирает ревьюверов случайным образом
+type RandomSelector struct {
+	rnd *rand.Rand
+}
+
+func (s *RandomSelector) Select(_ string, candidates []ReassignmentCandidate, count int) []ReassignmentCandidate {
+	shuffled := make([]ReassignmentCandidate, len(candidates))
+	copy(shuffled, candidates)
+
+	s.rnd.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return shuffled[:min(count, len(shuffled))]
+}
+
+// RoundRobinSelector выбирает ревьюверов по кругу в порядке ID.
+// Позиция хранится отдельно для каждой команды.
+type RoundRobinSelector struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func NewRoundRobinSelector() *RoundRobinSelector {
+	return &RoundRobinSelector{
+		last: make(map[string]string),
+	}
+}
+
+func (s *RoundRobinSelector) Select(teamID string, candidates []ReassignmentCandidate, count int) []ReassignmentCandidate {
+	if len(candidates) == 0 {
+		return []ReassignmentCandidate{}
+	}
+
+	ordered := make([]ReassignmentCandidate, len(candidates))
+	copy(ordered, candidates)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].UserID < ordered[j].UserID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Начинаем с первого кандидата после последнего выбранного
+	start := sort.Search(len(ordered), func(i int) bool {
+		return ordered[i].UserID > s.last[teamID]
+	})
+
+	count = min(count, len(ordered))
+	selected := make([]ReassignmentCandidate, 0, count)
+	for i := 0; i < count; i++ {
+		selected = append(selected, ordered[(start+i)%len(ordered)])
+	}
+
+	if count > 0 {
+		s.last[teamID] = selected[count-1].UserID
+	}
+
+	return selected
+}
+
+// LeastLoadedSelector выбирает ревьюверов с наименьшим числом открытых ревью.
+// При равной нагрузке порядок случайный.
+type LeastLoadedSelector struct {
+	rnd *rand.Rand
+}
+
+func (s *LeastLoadedSelector) Select(_ string, candidates []ReassignmentCandidate, count int) []ReassignmentCandidate {
+	ordered := make([]ReassignmentCandidate, len(candidates))
+	copy(ordered, candidates)
+
+	s.rnd.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].ReviewCount < ordered[j].ReviewCount
+	})
+
+	return ordered[:min(count, len(ordered))]
+}
+
+// DiversityWindow - сколько последних PR автора учитывает стратегия diverse
+const DiversityWindow = 5
+
+// DiverseSelector выбирает ревьюверов, которые реже других ревьюили последние PR автора,
+// чтобы знания о коде расходились по команде. При равенстве выбирается менее загруженный,
+// а при равной нагрузке порядок случайный.
+type DiverseSelector struct {
+	rnd *rand.Rand
+}
+
+func (s *DiverseSelector) Select(_ string, candidates []ReassignmentCandidate, count int) []ReassignmentCandidate {
+	ordered := make([]ReassignmentCandidate, len(candidates))
+	copy(ordered, candidates)
+
+	s.rnd.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].RecentPairings != ordered[j].RecentPairings {
+			return ordered[i].RecentPairings < ordered[j].RecentPairings
+		}
+		return ordered[i].ReviewCount < ordered[j].ReviewCount
+	})
+
+	return ordered[:min(count, len(ordered))]
+}
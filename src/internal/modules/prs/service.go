@@ -1,28 +1,74 @@
 package prs
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
+	"time"
 
 	"github.com/tomatoCoderq/avito_task/src/models"
+	"gorm.io/gorm"
 )
 
 type RepositoryMethods interface {
 	CreatePR(pr *models.PR) (*models.PR, error)
 	GetPRByID(prID string) (*models.PR, error)
-	MergePR(prID string) (*models.PR, error)
-	ReassignReviewer(prID string, oldUserID string, newUserID string) (*models.PR, error)
+	GetPRForUpdate(prID string) (*models.PR, error)
+	UpdateStatus(prID string, status string, at time.Time) (*models.PR, error)
+	ReassignReviewer(prID string, oldUserID string, newUserID string, at time.Time) (*models.PR, error)
 	GetUserByID(userID string) (*models.User, error)
-	GetActiveTeamMembers(teamID string, excludeUserID string) ([]models.User, error)
+	GetTeamByID(teamID string) (*models.Team, error)
+	GetActiveTeamMembers(teamID string, excludeUserID string, at time.Time) ([]models.User, error)
+	GetActiveUsersByIDs(userIDs []string, excludeUserID string, at time.Time) ([]models.User, error)
+	IsAbsent(userID string, at time.Time) (bool, error)
+	GetCodeOwnerRules(teamID string) ([]models.CodeOwnerRule, error)
+	CountOpenReviews(userIDs []string) (map[string]int, error)
+	CountRecentPairings(authorID string, userIDs []string, window int) (map[string]int, error)
+	GetTeamPolicy(teamID string) (*models.TeamPolicy, error)
+	GetActiveUsersOutsideTeam(teamID string, excludeUserIDs []string, at time.Time) ([]models.User, error)
+	AddReviewers(prID string, userIDs []string, needMoreReviewers bool, at time.Time) (*models.PR, error)
+	GetUnderstaffedPRIDs() ([]string, error)
+	ListPRs(filter PRListFilter) ([]models.PR, error)
+	CreateReview(review *models.PRReview) (*models.PRReview, error)
+	GetLatestVerdicts(prID string) (map[string]string, error)
+	RemoveReviewer(prID string, userID string, needMoreReviewers bool) (*models.PR, error)
+	SetPolicyUnsatisfied(prID string, unsatisfied bool) (*models.PR, error)
+	LockUsers(userIDs []string) error
+	WithTx(tx *gorm.DB) RepositoryMethods
+}
+
+// UnitOfWork выполняет операции нескольких репозиториев в одной транзакции
+type UnitOfWork interface {
+	Do(fn func(tx *gorm.DB) error) error
 }
 
 type Service struct {
-	repo RepositoryMethods
+	repo      RepositoryMethods
+	uow       UnitOfWork
+	selectors map[string]ReviewerSelector
+	clock     Clock
+}
+
+// RegisterService создает сервис PR. rnd задает случайность при выборе ревьюверов, clock - время смены статусов;
+// при фиксированном зерне rnd назначения на одних и тех же данных воспроизводимы
+func RegisterService(repo RepositoryMethods, uow UnitOfWork, rnd *rand.Rand, clock Clock) *Service {
+	return &Service{
+		repo:      repo,
+		uow:       uow,
+		selectors: NewSelectors(rnd),
+		clock:     clock,
+	}
 }
 
-func RegisterService(repo RepositoryMethods) *Service {
+// withTx возвращает копию сервиса, репозиторий которой работает в рамках транзакции tx
+func (s *Service) withTx(tx *gorm.DB) *Service {
 	return &Service{
-		repo: repo,
+		repo:      s.repo.WithTx(tx),
+		uow:       s.uow,
+		selectors: s.selectors,
+		clock:     s.clock,
 	}
 }
 
@@ -30,125 +76,1200 @@ func (s *Service) GetPRByID(prID string) (*models.PR, error) {
 	return s.repo.GetPRByID(prID)
 }
 
-func (s *Service) CreatePR(prID, prName, authorID string) (*models.PR, error) {
-	author, err := s.repo.GetUserByID(authorID)
+// CreatePR создает PR и назначает ревьюверов. Черновик создается без ревьюверов,
+// они назначаются при переводе в OPEN через MarkReady.
+// Выбор ревьюверов и вставка PR выполняются в одной транзакции с блокировкой кандидатов,
+// поэтому параллельная деактивация не может оставить PR с деактивированным ревьювером
+func (s *Service) CreatePR(data PRCreationData) (*models.PR, error) {
+	var pr *models.PR
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, err = s.withTx(tx).createPR(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (s *Service) createPR(data PRCreationData) (*models.PR, error) {
+	author, err := s.repo.GetUserByID(data.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+
+	team, err := authorTeam(author, data.TeamName)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(author.Teams) == 0 {
-		return nil, errors.New("author has no team")
+	now := s.clock.Now()
+
+	if data.Draft {
+		return s.repo.CreatePR(&models.PR{
+			ID:        data.PRID,
+			Name:      data.Name,
+			AuthorID:  author.ID,
+			TeamID:    team.ID,
+			Status:    models.PRStatusDraft,
+			Reviewers: []models.User{},
+
+			ChangedPaths: data.ChangedPaths,
+			RequiredTags: models.NormalizeTags(data.RequiredTags),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
 	}
 
-	teamMembers, err := s.repo.GetActiveTeamMembers(author.Teams[0].ID, author.ID)
+	policy, err := s.repo.GetTeamPolicy(team.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Выбираем до 2 ревьюверов случайным образом из активных членов команды
-	reviewers := s.selectReviewers(teamMembers, 2)
+	// Выбираем ревьюверов из активных членов команды по стратегии команды
+	requiredTags := models.NormalizeTags(data.RequiredTags)
+
+	reviewers, saturated, err := s.pickReviewers(team, policy, author.ID, nil, data.ChangedPaths, requiredTags, policy.ReviewerCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Не перегружаем ревьюверов сверх лимита: если подходящие кандидаты есть, но все заняты, сообщаем об этом
+	if policy.ReviewerCount > 0 && len(reviewers) == 0 && saturated {
+		return nil, errors.New("ALL_AT_CAPACITY: all candidate reviewers are at capacity")
+	}
+
+	// Правило старшинства: в режиме fail отказываем, в режиме flag создаем PR с флагом
+	unsatisfied, err := checkSeniority(policy, reviewers, true)
+	if err != nil {
+		return nil, err
+	}
 
 	pr := &models.PR{
-		ID:        prID,
-		Name:      prName,
+		ID:        data.PRID,
+		Name:      data.Name,
 		AuthorID:  author.ID,
-		Status:    "OPEN",
+		TeamID:    team.ID,
+		Status:    models.PRStatusOpen,
 		Reviewers: reviewers,
+
+		NeedMoreReviewers: len(reviewers) < policy.ReviewerCount,
+		ChangedPaths:      data.ChangedPaths,
+		RequiredTags:      requiredTags,
+		PolicyUnsatisfied: unsatisfied,
+		CreatedAt:         now,
+		UpdatedAt:         now,
 	}
 
 	return s.repo.CreatePR(pr)
 }
 
-func (s *Service) MergePR(prID string) (*models.PR, error) {
-	return s.repo.MergePR(prID)
+// FillReviewers доназначает ревьюверов в открытый PR до числа, требуемого политикой команды.
+// Возвращает обновленный PR и ID добавленных ревьюверов
+func (s *Service) FillReviewers(prID string) (*models.PR, []string, error) {
+	var (
+		pr       *models.PR
+		addedIDs []string
+	)
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, addedIDs, err = s.withTx(tx).fillReviewers(prID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pr, addedIDs, nil
 }
 
-func (s *Service) ReassignReviewer(prID, oldUserID string) (*models.PR, string, error) {
+func (s *Service) fillReviewers(prID string) (*models.PR, []string, error) {
 	pr, err := s.repo.GetPRByID(prID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pr.Status != models.PRStatusOpen {
+		return nil, nil, errors.New("PR_NOT_OPEN: reviewers can only be added to open PR")
+	}
+
+	team, policy, err := s.prTeamPolicy(pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing := policy.ReviewerCount - countActive(pr.Reviewers)
+	if missing <= 0 {
+		if !pr.NeedMoreReviewers {
+			updatedPR, err := s.syncSeniority(pr, policy)
+			return updatedPR, []string{}, err
+		}
+		updatedPR, err := s.repo.AddReviewers(pr.ID, nil, false, s.clock.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		updatedPR, err = s.syncSeniority(updatedPR, policy)
+		return updatedPR, []string{}, err
+	}
+
+	reviewers, _, err := s.pickReviewers(team, policy, pr.AuthorID, pr.Reviewers, pr.ChangedPaths, pr.RequiredTags, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addedIDs := make([]string, len(reviewers))
+	for i, reviewer := range reviewers {
+		addedIDs[i] = reviewer.ID
+	}
+
+	updatedPR, err := s.repo.AddReviewers(pr.ID, addedIDs, len(reviewers) < missing, s.clock.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updatedPR, err = s.syncSeniority(updatedPR, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return updatedPR, addedIDs, nil
+}
+
+// FillUnderstaffedPRs доназначает ревьюверов во все открытые PR с флагом NeedMoreReviewers.
+// Возвращает число PR, в которые удалось добавить хотя бы одного ревьювера
+func (s *Service) FillUnderstaffedPRs() (int, error) {
+	prIDs, err := s.repo.GetUnderstaffedPRIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	filled := 0
+	var errs []error
+	for _, prID := range prIDs {
+		_, addedIDs, err := s.FillReviewers(prID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(addedIDs) > 0 {
+			filled++
+		}
+	}
+
+	return filled, errors.Join(errs...)
+}
+
+// MergePR переводит PR в статус MERGED. Повторное слияние идемпотентно.
+// Без force требуется, чтобы число одобрений назначенных ревьюверов достигло порога политики команды
+func (s *Service) MergePR(prID string, force bool) (*models.PR, error) {
+	var pr *models.PR
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, err = s.withTx(tx).mergePR(prID, force)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+// mergePR блокирует строку PR, чтобы ревьюверов не сменили между подсчетом одобрений и слиянием
+func (s *Service) mergePR(prID string, force bool) (*models.PR, error) {
+	pr, err := s.repo.GetPRForUpdate(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Status == models.PRStatusMerged {
+		return pr, nil
+	}
+
+	// Сначала проверяем переход, чтобы закрытый PR или черновик получили ошибку статуса, а не NOT_APPROVED
+	if err := models.CheckTransition(pr.Status, models.PRStatusMerged); err != nil {
+		return nil, err
+	}
+
+	if !force {
+		approvals, required, err := s.countApprovals(pr)
+		if err != nil {
+			return nil, err
+		}
+		if approvals < required {
+			return nil, fmt.Errorf("NOT_APPROVED: %d of %d required approvals", approvals, required)
+		}
+	}
+
+	return s.repo.UpdateStatus(prID, models.PRStatusMerged, s.clock.Now())
+}
+
+// SubmitReview сохраняет вердикт назначенного ревьювера по открытому PR
+func (s *Service) SubmitReview(prID, reviewerID, verdict, comment string) (*models.PRReview, error) {
+	pr, err := s.repo.GetPRByID(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Status != models.PRStatusOpen {
+		return nil, errors.New("PR_NOT_OPEN: reviews can only be submitted on open PR")
+	}
+
+	if !isReviewer(pr, reviewerID) {
+		return nil, errors.New("NOT_ASSIGNED: reviewer is not assigned to this PR")
+	}
+
+	return s.repo.CreateReview(&models.PRReview{
+		PRID:       pr.ID,
+		ReviewerID: reviewerID,
+		Verdict:    verdict,
+		Comment:    comment,
+	})
+}
+
+// countApprovals возвращает число одобрений от назначенных сейчас ревьюверов и требуемый политикой порог
+func (s *Service) countApprovals(pr *models.PR) (int, int, error) {
+	_, policy, err := s.prTeamPolicy(pr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	verdicts, err := s.repo.GetLatestVerdicts(pr.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	approvals := 0
+	for _, reviewer := range pr.Reviewers {
+		if verdicts[reviewer.ID] == models.VerdictApproved {
+			approvals++
+		}
+	}
+
+	return approvals, policy.MinApprovals, nil
+}
+
+// AddReviewer вручную назначает пользователя ревьювером PR
+func (s *Service) AddReviewer(prID, userID string) (*models.PR, error) {
+	var pr *models.PR
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, err = s.withTx(tx).addReviewer(prID, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (s *Service) addReviewer(prID, userID string) (*models.PR, error) {
+	pr, err := s.repo.GetPRForUpdate(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Блокируем пользователя, чтобы его не деактивировали до конца назначения
+	if err := s.repo.LockUsers([]string{userID}); err != nil {
+		return nil, err
+	}
+
+	if pr.Status == models.PRStatusMerged {
+		return nil, errors.New("PR_MERGED: cannot change reviewers on merged PR")
+	}
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("USER_INACTIVE: reviewer is not active")
+	}
+
+	if user.ID == pr.AuthorID {
+		return nil, errors.New("AUTHOR_CANNOT_REVIEW: author cannot review own PR")
+	}
+
+	if isReviewer(pr, user.ID) {
+		return nil, errors.New("ALREADY_ASSIGNED: reviewer is already assigned to this PR")
+	}
+
+	_, policy, err := s.prTeamPolicy(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	needMore := countActive(pr.Reviewers)+1 < policy.ReviewerCount
+	updatedPR, err := s.repo.AddReviewers(pr.ID, []string{user.ID}, needMore, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.syncSeniority(updatedPR, policy)
+}
+
+// RemoveReviewer снимает ревьювера с PR и пересчитывает флаг NeedMoreReviewers
+func (s *Service) RemoveReviewer(prID, userID string) (*models.PR, error) {
+	var pr *models.PR
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, err = s.withTx(tx).removeReviewer(prID, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (s *Service) removeReviewer(prID, userID string) (*models.PR, error) {
+	pr, err := s.repo.GetPRForUpdate(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Status == models.PRStatusMerged {
+		return nil, errors.New("PR_MERGED: cannot change reviewers on merged PR")
+	}
+
+	if !isReviewer(pr, userID) {
+		return nil, errors.New("NOT_ASSIGNED: reviewer is not assigned to this PR")
+	}
+
+	_, policy, err := s.prTeamPolicy(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := excludeReviewers(pr.Reviewers, []models.User{{ID: userID}})
+	needMore := countActive(remaining) < policy.ReviewerCount
+	updatedPR, err := s.repo.RemoveReviewer(pr.ID, userID, needMore)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.syncSeniority(updatedPR, policy)
+}
+
+// checkSeniority проверяет правило старшинства политики для ревьюверов. Возвращает true, если правило
+// не выполнено и PR нужно пометить. При strict и действии fail вместо флага возвращается ошибка POLICY_UNSATISFIED
+func checkSeniority(policy *models.TeamPolicy, reviewers []models.User, strict bool) (bool, error) {
+	missing := policy.MissingSeniors(reviewers)
+	if missing == 0 {
+		return false, nil
+	}
+
+	if strict && policy.SeniorityAction == models.SeniorityActionFail {
+		return false, fmt.Errorf("POLICY_UNSATISFIED: PR needs %d more reviewers with seniority %s or above", missing, policy.SeniorityLevel)
+	}
+
+	return true, nil
+}
+
+// syncSeniority обновляет флаг PolicyUnsatisfied по текущим ревьюверам PR
+func (s *Service) syncSeniority(pr *models.PR, policy *models.TeamPolicy) (*models.PR, error) {
+	unsatisfied, _ := checkSeniority(policy, pr.Reviewers, false)
+	if unsatisfied == pr.PolicyUnsatisfied {
+		return pr, nil
+	}
+
+	return s.repo.SetPolicyUnsatisfied(pr.ID, unsatisfied)
+}
+
+// prTeamPolicy возвращает команду, по которой подбираются ревьюверы PR, и ее политику
+func (s *Service) prTeamPolicy(pr *models.PR) (models.Team, *models.TeamPolicy, error) {
+	team, err := s.prTeam(pr)
+	if err != nil {
+		return models.Team{}, nil, err
+	}
+
+	policy, err := s.repo.GetTeamPolicy(team.ID)
+	if err != nil {
+		return models.Team{}, nil, err
+	}
+
+	return team, policy, nil
+}
+
+// prTeam возвращает команду PR. Для PR, созданных до сохранения команды, берется основная команда автора
+func (s *Service) prTeam(pr *models.PR) (models.Team, error) {
+	if pr.TeamID != "" {
+		team, err := s.repo.GetTeamByID(pr.TeamID)
+		if err != nil {
+			return models.Team{}, err
+		}
+		return *team, nil
+	}
+
+	author, err := s.repo.GetUserByID(pr.AuthorID)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	return authorTeam(author, "")
+}
+
+// authorTeam выбирает команду нового PR: указанную в запросе, если автор в ней состоит,
+// иначе основную команду автора
+func authorTeam(author *models.User, teamName string) (models.Team, error) {
+	if teamName != "" {
+		team, ok := author.TeamByName(teamName)
+		if !ok {
+			return models.Team{}, errors.New("NOT_TEAM_MEMBER: author is not a member of team " + teamName)
+		}
+		return team, nil
+	}
+
+	team, ok := author.PrimaryTeam()
+	if !ok {
+		return models.Team{}, errors.New("author has no team")
+	}
+	return team, nil
+}
+
+// countActive возвращает число активных пользователей. Неактивные ревьюверы не учитываются в требуемом количестве
+func countActive(users []models.User) int {
+	active := 0
+	for _, user := range users {
+		if user.IsActive {
+			active++
+		}
+	}
+	return active
+}
+
+// isReviewer проверяет, назначен ли пользователь ревьювером PR
+func isReviewer(pr *models.PR, userID string) bool {
+	for _, reviewer := range pr.Reviewers {
+		if reviewer.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Размер страницы списка PR
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListPRs возвращает страницу PR по фильтру и непрозрачный курсор следующей страницы.
+// Пустой курсор означает, что страниц больше нет
+func (s *Service) ListPRs(filter PRListFilter, cursor string) ([]models.PR, string, error) {
+	if filter.SortBy == "" {
+		filter.SortBy = SortByCreatedAt
+	}
+	if filter.Order == "" {
+		filter.Order = OrderDesc
+	}
+	if filter.SortBy != SortByCreatedAt && filter.SortBy != SortByUpdatedAt && filter.SortBy != SortByID {
+		return nil, "", errors.New("INVALID_REQUEST: unknown sort_by")
+	}
+	if filter.Order != OrderAsc && filter.Order != OrderDesc {
+		return nil, "", errors.New("INVALID_REQUEST: unknown order")
+	}
+	if filter.Status != "" && !models.IsValidPRStatus(filter.Status) {
+		return nil, "", errors.New("INVALID_REQUEST: unknown status")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	filter.Limit = min(filter.Limit, maxListLimit)
+
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil || decoded.SortBy != filter.SortBy || decoded.Order != filter.Order {
+			return nil, "", errors.New("INVALID_CURSOR: cursor does not match the requested sorting")
+		}
+		if decoded.SortBy != SortByID {
+			if _, err := time.Parse(time.RFC3339Nano, decoded.Value); err != nil {
+				return nil, "", errors.New("INVALID_CURSOR: malformed cursor")
+			}
+		}
+		filter.Cursor = decoded
+	}
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+
+	prs, err := s.repo.ListPRs(filter)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if pr.Status == "MERGED" {
-		return nil, "", errors.New("PR_MERGED: cannot reassign on merged PR")
+	if len(prs) <= limit {
+		return prs, "", nil
 	}
 
-	oldUser, err := s.repo.GetUserByID(oldUserID)
+	prs = prs[:limit]
+	last := prs[limit-1]
+
+	next := PRListCursor{
+		SortBy: filter.SortBy,
+		Order:  filter.Order,
+		ID:     last.ID,
+	}
+	switch filter.SortBy {
+	case SortByCreatedAt:
+		next.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	case SortByUpdatedAt:
+		next.Value = last.UpdatedAt.Format(time.RFC3339Nano)
+	}
+
+	nextCursor, err := encodeCursor(next)
 	if err != nil {
 		return nil, "", err
 	}
 
-	isAssigned := false
+	return prs, nextCursor, nil
+}
+
+// ClosePR закрывает PR без слияния
+func (s *Service) ClosePR(prID string) (*models.PR, error) {
+	return s.repo.UpdateStatus(prID, models.PRStatusClosed, s.clock.Now())
+}
+
+// ReopenPR повторно открывает закрытый PR. Пока PR был закрыт, его ревьюверов могли деактивировать,
+// а закрытый черновик вовсе не имеет ревьюверов, поэтому неактивные ревьюверы снимаются
+// и PR доназначается так же, как при переводе черновика в OPEN
+func (s *Service) ReopenPR(prID string) (*models.PR, error) {
+	var pr *models.PR
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, err = s.withTx(tx).reopenPR(prID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (s *Service) reopenPR(prID string) (*models.PR, error) {
+	pr, err := s.repo.UpdateStatus(prID, models.PRStatusOpen, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	for _, reviewer := range pr.Reviewers {
-		if reviewer.ID == oldUserID {
-			isAssigned = true
+		if reviewer.IsActive {
+			continue
+		}
+		if _, err := s.repo.RemoveReviewer(pr.ID, reviewer.ID, pr.NeedMoreReviewers); err != nil {
+			return nil, err
+		}
+	}
+
+	// fillReviewers пересчитывает флаг NeedMoreReviewers по оставшимся ревьюверам
+	updatedPR, _, err := s.fillReviewers(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	return updatedPR, nil
+}
+
+// MarkReady переводит черновик в OPEN и назначает ревьюверов по политике команды
+func (s *Service) MarkReady(prID string) (*models.PR, error) {
+	var pr *models.PR
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, err = s.withTx(tx).markReady(prID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (s *Service) markReady(prID string) (*models.PR, error) {
+	pr, err := s.repo.GetPRByID(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Status != models.PRStatusDraft {
+		return nil, &models.TransitionError{From: pr.Status, To: models.PRStatusOpen}
+	}
+
+	if _, err := s.repo.UpdateStatus(prID, models.PRStatusOpen, s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	updatedPR, _, err := s.fillReviewers(prID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Перевод из черновика проверяет правило старшинства так же строго, как создание PR
+	_, policy, err := s.prTeamPolicy(updatedPR)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkSeniority(policy, updatedPR.Reviewers, true); err != nil {
+		return nil, err
+	}
+
+	return updatedPR, nil
+}
+
+// ReassignReviewer заменяет ревьювера PR. Замена выбирается стратегией команды или opts.Strategy,
+// либо задается явно через opts.NewReviewerID и проверяется по тем же правилам, что и автоматический выбор
+func (s *Service) ReassignReviewer(prID, oldUserID string, opts ReassignOptions) (*models.PR, *models.PRReassignmentInfo, error) {
+	var (
+		pr   *models.PR
+		info *models.PRReassignmentInfo
+	)
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		pr, info, err = s.withTx(tx).reassignReviewer(prID, oldUserID, opts)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pr, info, nil
+}
+
+func (s *Service) reassignReviewer(prID, oldUserID string, opts ReassignOptions) (*models.PR, *models.PRReassignmentInfo, error) {
+	pr, err := s.repo.GetPRByID(prID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pr.Status == models.PRStatusMerged {
+		return nil, nil, errors.New("PR_MERGED: cannot reassign on merged PR")
+	}
+
+	if pr.Status != models.PRStatusOpen {
+		return nil, nil, errors.New("PR_NOT_OPEN: reviewers can only be reassigned on open PR")
+	}
+
+	if _, err := s.repo.GetUserByID(oldUserID); err != nil {
+		return nil, nil, err
+	}
+
+	if !isReviewer(pr, oldUserID) {
+		return nil, nil, errors.New("NOT_ASSIGNED: reviewer is not assigned to this PR")
+	}
+
+	// Замена подбирается по команде PR, а не по одной из команд старого ревьювера
+	team, policy, err := s.prTeamPolicy(pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Кандидаты берутся из первой команды, где есть хотя бы один подходящий участник
+	pools, err := s.candidatePools(team, policy, pr.AuthorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		candidates []models.User
+		source     CandidatePool
+		saturated  int
+	)
+	for _, pool := range pools {
+		available, skipped, err := s.withinCapacity(excludeByPolicy(excludeReviewers(pool.Users, pr.Reviewers), policy))
+		if err != nil {
+			return nil, nil, err
+		}
+		saturated += skipped
+
+		if len(available) > 0 {
+			candidates = available
+			source = pool
 			break
 		}
 	}
 
-	if !isAssigned {
-		return nil, "", errors.New("NOT_ASSIGNED: reviewer is not assigned to this PR")
+	var newReviewer models.User
+	if opts.NewReviewerID != "" {
+		newReviewer, err = s.checkEligible(pr, opts.NewReviewerID, team, policy, candidates)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if len(candidates) == 0 && saturated > 0 {
+			return nil, nil, errors.New("ALL_AT_CAPACITY: all replacement candidates are at capacity")
+		}
+		if len(candidates) == 0 {
+			return nil, nil, errors.New("NO_CANDIDATE: no active replacement candidate in team")
+		}
+
+		if opts.Strategy != "" {
+			team.ReviewerStrategy = opts.Strategy
+		}
+
+		// Если со старым ревьювером уходит нужный политике старший, замену ищем среди старших
+		remaining := excludeReviewers(pr.Reviewers, []models.User{{ID: oldUserID}})
+		if policy.MissingSeniors(remaining) > 0 {
+			if seniors := seniorsOnly(candidates, policy.SeniorityLevel); len(seniors) > 0 {
+				candidates = seniors
+			}
+		}
+
+		// Предпочитаем кандидатов, покрывающих теги, которые снимаются вместе со старым ревьювером
+		if preferred := preferTagged(candidates, models.UncoveredTags(pr.RequiredTags, remaining)); len(preferred) > 0 {
+			candidates = preferred
+		}
+
+		// Выбираем кандидата по стратегии команды
+		selected, err := s.selectReviewers(team, pr.AuthorID, candidates, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		newReviewer = selected[0]
 	}
 
-	// Получаем команду старого пользователя
-	if len(oldUser.Teams) == 0 {
-		return nil, "", errors.New("user has no team")
+	// Замена не должна нарушать правило старшинства, которое выполнялось до нее
+	after := append(excludeReviewers(pr.Reviewers, []models.User{{ID: oldUserID}}), newReviewer)
+	if policy.MissingSeniors(after) > policy.MissingSeniors(pr.Reviewers) {
+		if _, err := checkSeniority(policy, after, true); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	// Получаем активных членов команды (исключая автора и текущих ревьюверов)
-	teamMembers, err := s.repo.GetActiveTeamMembers(oldUser.Teams[0].ID, pr.AuthorID)
+	// Переназначаем
+	updatedPR, err := s.repo.ReassignReviewer(prID, oldUserID, newReviewer.ID, s.clock.Now())
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	updatedPR, err = s.syncSeniority(updatedPR, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return updatedPR, &models.PRReassignmentInfo{
+		PRID:            prID,
+		FromReviewer:    oldUserID,
+		ToReviewer:      newReviewer.ID,
+		ReplacementTeam: poolTeamName(source, newReviewer),
+	}, nil
+}
+
+// checkEligible проверяет, что пользователь, выбранный вызывающей стороной, подходит на замену ревьювера.
+// Если нет, возвращает ошибку NOT_ELIGIBLE с причиной
+func (s *Service) checkEligible(pr *models.PR, userID string, team models.Team, policy *models.TeamPolicy, candidates []models.User) (models.User, error) {
+	for _, candidate := range candidates {
+		if candidate.ID == userID {
+			return candidate, nil
+		}
+	}
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return models.User{}, err
 	}
 
-	// Фильтруем текущих ревьюверов
-	candidates := make([]models.User, 0)
-	for _, member := range teamMembers {
+	absent, err := s.repo.IsAbsent(user.ID, s.clock.Now())
+	if err != nil {
+		return models.User{}, err
+	}
+
+	atCapacity := false
+	if user.MaxOpenReviews > 0 {
+		_, skipped, err := s.withinCapacity([]models.User{*user})
+		if err != nil {
+			return models.User{}, err
+		}
+		atCapacity = skipped > 0
+	}
+
+	var reason string
+	switch {
+	case !user.IsActive:
+		reason = "user is not active"
+	case user.OutOfRotation:
+		reason = "user is out of review rotation"
+	case absent:
+		reason = "user is absent"
+	case user.IsSnoozed(s.clock.Now()):
+		reason = "user is snoozed until " + user.SnoozedUntil.Format(time.RFC3339)
+	case user.ID == pr.AuthorID:
+		reason = "user is the author of the PR"
+	case isReviewer(pr, user.ID):
+		reason = "user is already assigned to this PR"
+	case policy.IsExcluded(user.ID):
+		reason = "user is excluded from auto-assignment by team policy"
+	case atCapacity:
+		reason = "user is at review capacity"
+	case !policy.AllowCrossTeam && !inTeams(user, team, policy):
+		reason = "user is not a member of team " + team.Name + " or its fallback teams"
+	default:
+		// Участник запасной команды подходит, только если в команде PR не осталось доступных кандидатов
+		reason = "user is not in the candidate pool for this PR's team"
+	}
+
+	return models.User{}, errors.New("NOT_ELIGIBLE: " + reason)
+}
+
+// inTeams сообщает, состоит ли пользователь в команде PR или в одной из ее запасных команд
+func inTeams(user *models.User, team models.Team, policy *models.TeamPolicy) bool {
+	if user.InTeam(team.ID) {
+		return true
+	}
+	for _, fallback := range policy.FallbackTeams {
+		if user.InTeam(fallback.FallbackTeamID) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeReviewers убирает из кандидатов уже назначенных ревьюверов
+func excludeReviewers(candidates []models.User, reviewers []models.User) []models.User {
+	result := make([]models.User, 0, len(candidates))
+	for _, candidate := range candidates {
 		isCurrentReviewer := false
-		for _, reviewer := range pr.Reviewers {
-			if member.ID == reviewer.ID {
+		for _, reviewer := range reviewers {
+			if candidate.ID == reviewer.ID {
 				isCurrentReviewer = true
 				break
 			}
 		}
 		if !isCurrentReviewer {
-			candidates = append(candidates, member)
+			result = append(result, candidate)
 		}
 	}
+	return result
+}
 
-	if len(candidates) == 0 {
-		return nil, "", errors.New("NO_CANDIDATE: no active replacement candidate in team")
+// excludeByPolicy убирает из кандидатов пользователей, исключенных политикой команды
+func excludeByPolicy(candidates []models.User, policy *models.TeamPolicy) []models.User {
+	result := make([]models.User, 0, len(candidates))
+	for _, candidate := range candidates {
+		if !policy.IsExcluded(candidate.ID) {
+			result = append(result, candidate)
+		}
 	}
+	return result
+}
 
-	// Выбираем случайного кандидата
-	newReviewer := candidates[rand.Intn(len(candidates))]
+// pickReviewers выбирает до count ревьюверов из активных членов команды, кроме автора и уже назначенных.
+// Если в команде не хватает людей, ревьюверы добираются из запасных и, если политика это разрешает, других команд.
+// Кандидаты с достигнутым лимитом открытых ревью пропускаются; второе значение сообщает, что такие были.
+// По измененным путям paths сначала назначаются владельцы кода из CODEOWNERS команды,
+// затем кандидаты, покрывающие требуемые теги tags, и только потом остальные
+func (s *Service) pickReviewers(team models.Team, policy *models.TeamPolicy, authorID string, assigned []models.User, paths []string, tags []string, count int) ([]models.User, bool, error) {
+	if count <= 0 {
+		return []models.User{}, false, nil
+	}
 
-	// Переназначаем
-	updatedPR, err := s.repo.ReassignReviewer(prID, oldUserID, newReviewer.ID)
+	// Владельцы измененного кода назначаются первыми и занимают места наравне с остальными
+	reviewers, saturated, err := s.pickOwners(team, policy, authorID, assigned, paths)
 	if err != nil {
-		return nil, "", err
+		return nil, false, err
+	}
+
+	pools, err := s.candidatePools(team, policy, authorID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Сначала добираем ревьюверов уровня, которого требует политика
+	for _, pool := range pools {
+		missing := policy.MissingSeniors(append(append([]models.User{}, assigned...), reviewers...))
+		if missing == 0 || len(reviewers) >= count {
+			break
+		}
+
+		candidates, skipped, err := s.withinCapacity(excludeByPolicy(excludeReviewers(pool.Users, append(assigned, reviewers...)), policy))
+		if err != nil {
+			return nil, false, err
+		}
+		saturated = saturated || skipped > 0
+
+		selected, err := s.selectReviewers(team, authorID, seniorsOnly(candidates, policy.SeniorityLevel), min(missing, count-len(reviewers)))
+		if err != nil {
+			return nil, false, err
+		}
+		reviewers = append(reviewers, selected...)
+	}
+
+	// Жадно добираем кандидатов, покрывающих больше всего еще не покрытых тегов
+	uncovered := models.UncoveredTags(tags, append(append([]models.User{}, assigned...), reviewers...))
+	for _, pool := range pools {
+		for len(uncovered) > 0 && len(reviewers) < count {
+			candidates, skipped, err := s.withinCapacity(excludeByPolicy(excludeReviewers(pool.Users, append(assigned, reviewers...)), policy))
+			if err != nil {
+				return nil, false, err
+			}
+			saturated = saturated || skipped > 0
+
+			preferred := preferTagged(candidates, uncovered)
+			if len(preferred) == 0 {
+				break
+			}
+
+			selected, err := s.selectReviewers(team, authorID, preferred, 1)
+			if err != nil {
+				return nil, false, err
+			}
+			reviewers = append(reviewers, selected...)
+			uncovered = models.UncoveredTags(uncovered, selected)
+		}
+	}
+
+	for _, pool := range pools {
+		if len(reviewers) >= count {
+			break
+		}
+
+		candidates, skipped, err := s.withinCapacity(excludeByPolicy(excludeReviewers(pool.Users, append(assigned, reviewers...)), policy))
+		if err != nil {
+			return nil, false, err
+		}
+		saturated = saturated || skipped > 0
+
+		selected, err := s.selectReviewers(team, authorID, candidates, count-len(reviewers))
+		if err != nil {
+			return nil, false, err
+		}
+		reviewers = append(reviewers, selected...)
+	}
+
+	return reviewers, saturated, nil
+}
+
+// seniorsOnly оставляет кандидатов с уровнем старшинства не ниже level
+func seniorsOnly(candidates []models.User, level string) []models.User {
+	result := make([]models.User, 0, len(candidates))
+	for i := range candidates {
+		if candidates[i].SeniorityAtLeast(level) {
+			result = append(result, candidates[i])
+		}
+	}
+	return result
+}
+
+// preferTagged возвращает кандидатов, покрывающих наибольшее число тегов, или пустой список,
+// если ни один кандидат не покрывает ни одного тега
+func preferTagged(candidates []models.User, tags []string) []models.User {
+	preferred := []models.User{}
+	best := 0
+	for _, candidate := range candidates {
+		covered := len(tags) - len(models.UncoveredTags(tags, []models.User{candidate}))
+		switch {
+		case covered == 0 || covered < best:
+			continue
+		case covered > best:
+			best = covered
+			preferred = preferred[:0]
+		}
+		preferred = append(preferred, candidate)
+	}
+	return preferred
+}
+
+// pickOwners выбирает по одному владельцу для каждого правила CODEOWNERS команды, под которое
+// попадают измененные пути, если правило еще не покрыто назначенными ревьюверами.
+// Владелец выбирается стратегией команды среди доступных владельцев правила, даже если их больше нужного числа ревьюверов
+func (s *Service) pickOwners(team models.Team, policy *models.TeamPolicy, authorID string, assigned []models.User, paths []string) ([]models.User, bool, error) {
+	owners := []models.User{}
+	if len(paths) == 0 {
+		return owners, false, nil
+	}
+
+	rules, err := s.repo.GetCodeOwnerRules(team.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	saturated := false
+	for _, rule := range models.MatchCodeOwnerRules(rules, paths) {
+		taken := make([]models.User, 0, len(assigned)+len(owners))
+		taken = append(append(taken, assigned...), owners...)
+		if len(rule.Owners) == 0 || len(excludeReviewers(rule.Owners, taken)) < len(rule.Owners) {
+			continue
+		}
+
+		ownerIDs := make([]string, len(rule.Owners))
+		for i, owner := range rule.Owners {
+			ownerIDs[i] = owner.ID
+		}
+
+		active, err := s.repo.GetActiveUsersByIDs(ownerIDs, authorID, s.clock.Now())
+		if err != nil {
+			return nil, false, err
+		}
+
+		candidates, skipped, err := s.withinCapacity(excludeByPolicy(active, policy))
+		if err != nil {
+			return nil, false, err
+		}
+		saturated = saturated || skipped > 0
+
+		selected, err := s.selectReviewers(team, authorID, candidates, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		owners = append(owners, selected...)
+	}
+
+	return owners, saturated, nil
+}
+
+// withinCapacity отсеивает кандидатов, у которых число открытых ревью достигло личного лимита.
+// Возвращает оставшихся кандидатов и число отсеянных
+func (s *Service) withinCapacity(candidates []models.User) ([]models.User, int, error) {
+	limitedIDs := []string{}
+	for _, candidate := range candidates {
+		if candidate.MaxOpenReviews > 0 {
+			limitedIDs = append(limitedIDs, candidate.ID)
+		}
+	}
+	if len(limitedIDs) == 0 {
+		return candidates, 0, nil
+	}
+
+	counts, err := s.repo.CountOpenReviews(limitedIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	available := make([]models.User, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.MaxOpenReviews > 0 && counts[candidate.ID] >= candidate.MaxOpenReviews {
+			continue
+		}
+		available = append(available, candidate)
 	}
 
-	return updatedPR, newReviewer.ID, nil
+	return available, len(candidates) - len(available), nil
 }
 
-func (s *Service) selectReviewers(candidates []models.User, maxCount int) []models.User {
+// candidatePools возвращает активных кандидатов в ревьюверы по командам в порядке приоритета:
+// своя команда, запасные команды из политики, затем остальные команды, если политика это разрешает.
+// Автор PR в пулы не попадает
+func (s *Service) candidatePools(team models.Team, policy *models.TeamPolicy, authorID string) ([]CandidatePool, error) {
+	teamMembers, err := s.repo.GetActiveTeamMembers(team.ID, authorID, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	pools := []CandidatePool{{Team: team, Users: teamMembers}}
+
+	for _, fallback := range policy.FallbackTeams {
+		members, err := s.repo.GetActiveTeamMembers(fallback.FallbackTeamID, authorID, s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, CandidatePool{Team: fallback.FallbackTeam, Users: members})
+	}
+
+	if policy.AllowCrossTeam {
+		outsiders, err := s.repo.GetActiveUsersOutsideTeam(team.ID, []string{authorID}, s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, CandidatePool{Users: outsiders})
+	}
+
+	return pools, nil
+}
+
+// poolTeamName возвращает имя команды, из которой взят ревьювер
+func poolTeamName(pool CandidatePool, user models.User) string {
+	if pool.Team.Name != "" {
+		return pool.Team.Name
+	}
+	if team, ok := user.PrimaryTeam(); ok {
+		return team.Name
+	}
+	return ""
+}
+
+// selectReviewers выбирает до maxCount ревьюверов стратегией, настроенной для команды.
+// Для стратегии diverse кандидатам подсчитывается, сколько из последних PR автора authorID они ревьюили
+func (s *Service) selectReviewers(team models.Team, authorID string, candidates []models.User, maxCount int) ([]models.User, error) {
 	if len(candidates) == 0 {
-		return []models.User{}
+		return []models.User{}, nil
+	}
+
+	userIDs := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		userIDs[i] = candidate.ID
 	}
 
-	count := maxCount
-	if len(candidates) < count {
-		count = len(candidates)
+	reviewCounts, err := s.repo.CountOpenReviews(userIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	shuffled := make([]models.User, len(candidates))
-	copy(shuffled, candidates)
+	recentPairings := map[string]int{}
+	if team.ReviewerStrategy == models.ReviewerStrategyDiverse {
+		recentPairings, err = s.repo.CountRecentPairings(authorID, userIDs, DiversityWindow)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	rand.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	})
+	usersByID := make(map[string]models.User, len(candidates))
+	pool := make([]ReassignmentCandidate, len(candidates))
+	for i, candidate := range candidates {
+		usersByID[candidate.ID] = candidate
+		pool[i] = ReassignmentCandidate{
+			UserID:      candidate.ID,
+			Username:    candidate.Name,
+			TeamID:      team.ID,
+			IsActive:    candidate.IsActive,
+			ReviewCount: reviewCounts[candidate.ID],
 
-	// Возвращаем до двух случайных ревьюверов
-	return shuffled[:count]
+			RecentPairings: recentPairings[candidate.ID],
+		}
+	}
+
+	selected := s.selectorFor(team).Select(team.ID, pool, maxCount)
+
+	reviewers := make([]models.User, len(selected))
+	for i, candidate := range selected {
+		reviewers[i] = usersByID[candidate.UserID]
+	}
+
+	return reviewers, nil
+}
+
+// selectorFor возвращает стратегию команды. Неизвестная стратегия заменяется случайной
+func (s *Service) selectorFor(team models.Team) ReviewerSelector {
+	if selector, ok := s.selectors[team.ReviewerStrategy]; ok {
+		return selector
+	}
+	return s.selectors[models.ReviewerStrategyRandom]
+}
+
+// encodeCursor кодирует позицию списка в непрозрачную строку
+func encodeCursor(cursor PRListCursor) (string, error) {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor восстанавливает позицию списка из строки курсора
+func decodeCursor(cursor string) (*PRListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded PRListCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return &decoded, nil
 }
@@ -0,0 +1,219 @@
+package prs
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tomatoCoderq/avito_task/src/models"
+)
+
+// fakeRepo отдает фиксированный состав команды и запоминает момент, с которым запрашивались кандидаты
+type fakeRepo struct {
+	RepositoryMethods
+
+	members []models.User
+	load    map[string]int
+	asked   []time.Time
+}
+
+func (r *fakeRepo) GetActiveTeamMembers(_ string, excludeUserID string, at time.Time) ([]models.User, error) {
+	r.asked = append(r.asked, at)
+
+	users := []models.User{}
+	for _, member := range r.members {
+		if member.ID != excludeUserID {
+			users = append(users, member)
+		}
+	}
+	return users, nil
+}
+
+func (r *fakeRepo) CountOpenReviews(userIDs []string) (map[string]int, error) {
+	counts := make(map[string]int, len(userIDs))
+	for _, userID := range userIDs {
+		counts[userID] = r.load[userID]
+	}
+	return counts, nil
+}
+
+func (r *fakeRepo) CountRecentPairings(string, []string, int) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+func newFakeRepo() *fakeRepo {
+	repo := &fakeRepo{load: map[string]int{}}
+	for i := 1; i <= 10; i++ {
+		id := fmt.Sprintf("u%02d", i)
+		repo.members = append(repo.members, models.User{ID: id, Name: id, IsActive: true})
+		// Одинаковая нагрузка у пар кандидатов, чтобы least_loaded и diverse решали ничьи случайно
+		repo.load[id] = i / 2
+	}
+	return repo
+}
+
+// pickSequence выбирает ревьюверов для нескольких PR подряд и возвращает их ID
+func pickSequence(t *testing.T, seed int64, strategy string) [][]string {
+	t.Helper()
+
+	service := RegisterService(newFakeRepo(), nil, NewRand(seed), FixedClock{})
+	team := models.Team{ID: "team", Name: "team", ReviewerStrategy: strategy}
+	policy := models.DefaultTeamPolicy(team.ID)
+
+	picks := [][]string{}
+	for i := 0; i < 5; i++ {
+		reviewers, _, err := service.pickReviewers(team, policy, "u01", nil, nil, nil, 2)
+		if err != nil {
+			t.Fatalf("pickReviewers: %v", err)
+		}
+
+		ids := make([]string, len(reviewers))
+		for j, reviewer := range reviewers {
+			ids[j] = reviewer.ID
+		}
+		picks = append(picks, ids)
+	}
+	return picks
+}
+
+func TestPickReviewersReproducibleForSeed(t *testing.T) {
+	strategies := []string{
+		models.ReviewerStrategyRandom,
+		models.ReviewerStrategyRoundRobin,
+		models.ReviewerStrategyLeastLoaded,
+		models.ReviewerStrategyDiverse,
+	}
+
+	for _, strategy := range strategies {
+		t.Run(strategy, func(t *testing.T) {
+			first := pickSequence(t, 42, strategy)
+			second := pickSequence(t, 42, strategy)
+
+			if !reflect.DeepEqual(first, second) {
+				t.Fatalf("same seed gave different assignments: %v and %v", first, second)
+			}
+			for _, ids := range first {
+				if len(ids) != 2 {
+					t.Fatalf("expected 2 reviewers, got %v", ids)
+				}
+			}
+		})
+	}
+}
+
+func TestPickReviewersUsesInjectedClock(t *testing.T) {
+	at := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+	repo := newFakeRepo()
+	service := RegisterService(repo, nil, NewRand(1), FixedClock{At: at})
+
+	team := models.Team{ID: "team", Name: "team", ReviewerStrategy: models.ReviewerStrategyRandom}
+	if _, _, err := service.pickReviewers(team, models.DefaultTeamPolicy(team.ID), "u01", nil, nil, nil, 2); err != nil {
+		t.Fatalf("pickReviewers: %v", err)
+	}
+
+	if len(repo.asked) == 0 {
+		t.Fatal("candidates were not requested")
+	}
+	for _, asked := range repo.asked {
+		if !asked.Equal(at) {
+			t.Fatalf("candidates requested at %v, want %v", asked, at)
+		}
+	}
+}
+
+// eligibilityRepo отдает пользователей по ID и список отсутствующих
+type eligibilityRepo struct {
+	*fakeRepo
+
+	users  map[string]models.User
+	absent map[string]bool
+}
+
+func (r *eligibilityRepo) GetUserByID(userID string) (*models.User, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user %s not found", userID)
+	}
+	return &user, nil
+}
+
+func (r *eligibilityRepo) IsAbsent(userID string, _ time.Time) (bool, error) {
+	return r.absent[userID], nil
+}
+
+func TestCheckEligibleReasons(t *testing.T) {
+	at := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+	snoozedUntil := at.Add(time.Hour)
+
+	team := models.Team{ID: "backend", Name: "backend"}
+	fallback := models.Team{ID: "platform", Name: "platform"}
+	other := models.Team{ID: "mobile", Name: "mobile"}
+
+	policy := models.DefaultTeamPolicy(team.ID)
+	policy.FallbackTeams = []models.TeamFallback{{TeamID: team.ID, FallbackTeamID: fallback.ID, FallbackTeam: fallback}}
+	policy.ExcludedUsers = []models.User{{ID: "excluded"}}
+
+	member := func(id string, teams ...models.Team) models.User {
+		return models.User{ID: id, IsActive: true, Teams: teams}
+	}
+	users := []models.User{
+		member("candidate", team),
+		member("author", team),
+		member("reviewer", team),
+		member("excluded", team),
+		member("away", team),
+		member("outsider", other),
+		member("backup", fallback),
+		{ID: "inactive", Teams: []models.Team{team}},
+		{ID: "rotation", IsActive: true, OutOfRotation: true, Teams: []models.Team{team}},
+		{ID: "snoozed", IsActive: true, SnoozedUntil: &snoozedUntil, Teams: []models.Team{team}},
+		{ID: "busy", IsActive: true, MaxOpenReviews: 2, Teams: []models.Team{team}},
+		{ID: "free", IsActive: true, MaxOpenReviews: 5, Teams: []models.Team{team}},
+	}
+
+	repo := &eligibilityRepo{fakeRepo: newFakeRepo(), users: map[string]models.User{}, absent: map[string]bool{"away": true}}
+	for _, user := range users {
+		repo.users[user.ID] = user
+	}
+	repo.load["busy"] = 2
+	repo.load["free"] = 1
+
+	service := RegisterService(repo, nil, NewRand(1), FixedClock{At: at})
+	pr := &models.PR{ID: "pr-1", AuthorID: "author", TeamID: team.ID, Reviewers: []models.User{{ID: "reviewer"}}}
+	candidates := []models.User{repo.users["candidate"]}
+
+	tests := []struct {
+		userID string
+		want   string
+	}{
+		{"inactive", "NOT_ELIGIBLE: user is not active"},
+		{"rotation", "NOT_ELIGIBLE: user is out of review rotation"},
+		{"away", "NOT_ELIGIBLE: user is absent"},
+		{"snoozed", "NOT_ELIGIBLE: user is snoozed until " + snoozedUntil.Format(time.RFC3339)},
+		{"author", "NOT_ELIGIBLE: user is the author of the PR"},
+		{"reviewer", "NOT_ELIGIBLE: user is already assigned to this PR"},
+		{"excluded", "NOT_ELIGIBLE: user is excluded from auto-assignment by team policy"},
+		{"busy", "NOT_ELIGIBLE: user is at review capacity"},
+		{"outsider", "NOT_ELIGIBLE: user is not a member of team backend or its fallback teams"},
+		// Участник команды под лимитом и участник запасной команды состоят в командах, но не попали в кандидаты
+		{"free", "NOT_ELIGIBLE: user is not in the candidate pool for this PR's team"},
+		{"backup", "NOT_ELIGIBLE: user is not in the candidate pool for this PR's team"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userID, func(t *testing.T) {
+			_, err := service.checkEligible(pr, tt.userID, team, policy, candidates)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("candidate", func(t *testing.T) {
+		user, err := service.checkEligible(pr, "candidate", team, policy, candidates)
+		if err != nil || user.ID != "candidate" {
+			t.Errorf("got %v, %v, want candidate", user.ID, err)
+		}
+	})
+}
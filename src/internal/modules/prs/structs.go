@@ -1,21 +1,45 @@
 package prs
 
+import (
+	"time"
+
+	"github.com/tomatoCoderq/avito_task/src/models"
+)
+
 // PRCreationData - данные для создания PR (внутренняя структура)
 type PRCreationData struct {
-	PRID     string   `json:"pr_id"`
-	Name     string   `json:"name"`
-	AuthorID string   `json:"author_id"`
-	TeamID   string   `json:"team_id"`
-	ReviewerIDs []string `json:"reviewer_ids"`
+	PRID         string   `json:"pr_id"`
+	Name         string   `json:"name"`
+	AuthorID     string   `json:"author_id"`
+	TeamID       string   `json:"team_id"`
+	TeamName     string   `json:"team_name"`
+	ChangedPaths []string `json:"changed_paths"`
+	RequiredTags []string `json:"required_tags"`
+	ReviewerIDs  []string `json:"reviewer_ids"`
+	Draft        bool     `json:"draft"`
 }
 
 // ReassignmentCandidate - кандидат для переназначения (внутренняя структура)
 type ReassignmentCandidate struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	TeamID   string `json:"team_id"`
-	IsActive bool   `json:"is_active"`
-	ReviewCount int `json:"review_count"` // Для балансировки нагрузки
+	UserID         string `json:"user_id"`
+	Username       string `json:"username"`
+	TeamID         string `json:"team_id"`
+	IsActive       bool   `json:"is_active"`
+	ReviewCount    int    `json:"review_count"`    // Для балансировки нагрузки
+	RecentPairings int    `json:"recent_pairings"` // Сколько из последних PR автора кандидат ревьюил
+}
+
+// CandidatePool - активные кандидаты в ревьюверы из одной команды (внутренняя структура).
+// Нулевая Team означает кандидатов из любых других команд
+type CandidatePool struct {
+	Team  models.Team
+	Users []models.User
+}
+
+// ReassignOptions - необязательные параметры переназначения ревьювера
+type ReassignOptions struct {
+	NewReviewerID string
+	Strategy      string
 }
 
 // PRMergeResult - результат слияния PR (внутренняя структура)
@@ -24,4 +48,40 @@ type PRMergeResult struct {
 	PreviousStatus string `json:"previous_status"`
 	NewStatus      string `json:"new_status"`
 	MergedAt       string `json:"merged_at"`
-}
\ No newline at end of file
+}
+
+// Поля сортировки списка PR
+const (
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	SortByID        = "id"
+)
+
+// Направления сортировки списка PR
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// PRListFilter - фильтры, сортировка и пагинация списка PR
+type PRListFilter struct {
+	Status            string
+	AuthorID          string
+	ReviewerID        string
+	TeamName          string
+	NeedMoreReviewers *bool
+	CreatedFrom       *time.Time
+	CreatedTo         *time.Time
+	SortBy            string
+	Order             string
+	Limit             int
+	Cursor            *PRListCursor
+}
+
+// PRListCursor - позиция в списке PR: значение поля сортировки и ID последнего элемента страницы
+type PRListCursor struct {
+	SortBy string `json:"s"`
+	Order  string `json:"o"`
+	Value  string `json:"v"`
+	ID     string `json:"id"`
+}
@@ -0,0 +1,81 @@
+package prs
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sweeper в фоне доназначает ревьюверов в PR, которым их не хватает.
+// Проход запускается по таймеру и по сигналу Trigger, например после добавления
+// в команду новых активных участников.
+type Sweeper struct {
+	service  *Service
+	interval time.Duration
+	trigger  chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	start    sync.Once
+	started  bool
+}
+
+func NewSweeper(service *Service, interval time.Duration) *Sweeper {
+	return &Sweeper{
+		service:  service,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start запускает фоновый цикл. Повторные вызовы игнорируются
+func (s *Sweeper) Start() {
+	s.start.Do(func() {
+		s.started = true
+		go s.run()
+	})
+}
+
+// Stop останавливает фоновый цикл и дожидается завершения текущего прохода
+func (s *Sweeper) Stop() {
+	s.start.Do(func() {})
+	if !s.started {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+}
+
+// Trigger запрашивает внеочередной проход. Не блокирует, если проход уже запрошен
+func (s *Sweeper) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Sweeper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+
+		filled, err := s.service.FillUnderstaffedPRs()
+		if err != nil {
+			slog.Error("reviewer sweep failed", "error", err)
+		}
+		if filled > 0 {
+			slog.Info("reviewer sweep completed", "filled_prs", filled)
+		}
+	}
+}
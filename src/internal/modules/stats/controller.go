@@ -11,6 +11,7 @@ type ServiceMethods interface {
 	GetUserStats(userID string) (*UserStats, error)
 	GetOverviewStats() (*OverviewStats, error)
 	GetTeamStats(teamName string) (*TeamStats, error)
+	GetPairStats(teamName string) (*PairStats, error)
 }
 
 type Controller struct {
@@ -144,3 +145,51 @@ func (c *Controller) GetTeamStats(ctx *gin.Context) {
 		},
 	})
 }
+
+// GetPairStats возвращает матрицу пар автор-ревьювер команды
+func (c *Controller) GetPairStats(ctx *gin.Context) {
+	teamName := ctx.Query("team_name")
+	if teamName == "" {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "team_name query parameter is required",
+			},
+		})
+		return
+	}
+
+	stats, err := c.service.GetPairStats(teamName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "team not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to get pair statistics",
+			},
+		})
+		return
+	}
+
+	matrix := make(map[string]map[string]int)
+	for _, pair := range stats.Pairs {
+		if matrix[pair.AuthorID] == nil {
+			matrix[pair.AuthorID] = make(map[string]int)
+		}
+		matrix[pair.AuthorID][pair.ReviewerID] = pair.Count
+	}
+
+	ctx.JSON(200, gin.H{
+		"team_name": stats.TeamName,
+		"pairs":     stats.Pairs,
+		"matrix":    matrix,
+	})
+}
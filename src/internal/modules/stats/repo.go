@@ -26,8 +26,8 @@ func (r *Repo) GetUserStats(userID string) (*UserStats, error) {
     
     stats.UserID = user.ID
     stats.Username = user.Name
-    if len(user.Teams) > 0 {
-        stats.TeamName = user.Teams[0].Name
+    if team, ok := user.PrimaryTeam(); ok {
+        stats.TeamName = team.Name
     }
     
     var totalAuthored, openAuthored, mergedAuthored int64
@@ -138,7 +138,7 @@ func (r *Repo) GetTeamStats(teamName string) (*TeamStats, error) {
 	stats.TotalMembers = memberStats.TotalMembers
 	stats.ActiveMembers = memberStats.ActiveMembers
 
-	// Статистика PR команды
+	// Статистика PR команды: PR относится к команде, выбранной при создании, а не ко всем командам автора
 	var prStats PRStats
 
 	if err := r.db.Raw(`
@@ -147,8 +147,7 @@ func (r *Repo) GetTeamStats(teamName string) (*TeamStats, error) {
 			COUNT(*) FILTER (WHERE p.status = 'OPEN') as open,
 			COUNT(*) FILTER (WHERE p.status = 'MERGED') as merged
 		FROM prs p
-		JOIN team_users tu ON p.author_id = tu.user_id
-		WHERE tu.team_id = ?`, team.ID).Scan(&prStats).Error; err != nil {
+		WHERE p.team_id = ?`, team.ID).Scan(&prStats).Error; err != nil {
 		return nil, err
 	}
 
@@ -165,7 +164,7 @@ func (r *Repo) GetTeamStats(teamName string) (*TeamStats, error) {
 			COUNT(p.id) as authored_count
 		FROM users u
 		JOIN team_users tu ON u.id = tu.user_id
-		LEFT JOIN prs p ON u.id = p.author_id
+		LEFT JOIN prs p ON u.id = p.author_id AND p.team_id = tu.team_id
 		WHERE tu.team_id = ? AND u.is_active = true
 		GROUP BY u.id, u.name
 		ORDER BY authored_count DESC
@@ -177,3 +176,31 @@ func (r *Repo) GetTeamStats(teamName string) (*TeamStats, error) {
 
 	return &stats, nil
 }
+
+// GetPairStats возвращает, сколько PR каждого автора команды получал на ревью каждый ревьювер.
+// Считается по истории назначений, поэтому снятые позже ревьюверы тоже учитываются
+func (r *Repo) GetPairStats(teamName string) (*PairStats, error) {
+	var team models.Team
+	if err := r.db.Where("name = ?", teamName).First(&team).Error; err != nil {
+		return nil, err
+	}
+
+	stats := PairStats{
+		TeamName: teamName,
+		Pairs:    []PairCount{},
+	}
+	if err := r.db.Raw(`
+		SELECT
+			author_id,
+			reviewer_id,
+			COUNT(DISTINCT pr_id) as count
+		FROM review_assignments
+		WHERE team_id = ?
+		GROUP BY author_id, reviewer_id
+		ORDER BY count DESC, author_id, reviewer_id
+	`, team.ID).Scan(&stats.Pairs).Error; err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
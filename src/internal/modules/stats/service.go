@@ -4,6 +4,7 @@ type RepositoryMethods interface {
 	GetUserStats(userID string) (*UserStats, error)
 	GetOverviewStats() (*OverviewStats, error)
 	GetTeamStats(teamName string) (*TeamStats, error)
+	GetPairStats(teamName string) (*PairStats, error)
 }
 
 type Service struct {
@@ -26,4 +27,8 @@ func (s *Service) GetOverviewStats() (*OverviewStats, error) {
 
 func (s *Service) GetTeamStats(teamName string) (*TeamStats, error) {
 	return s.repo.GetTeamStats(teamName)
+}
+
+func (s *Service) GetPairStats(teamName string) (*PairStats, error) {
+	return s.repo.GetPairStats(teamName)
 }
\ No newline at end of file
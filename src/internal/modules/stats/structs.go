@@ -84,4 +84,17 @@ type PRStats struct {
 	Total  int
 	Open   int
 	Merged int
-}
\ No newline at end of file
+}
+
+// PairStats - матрица пар автор-ревьювер в PR команды
+type PairStats struct {
+	TeamName string      `json:"team_name"`
+	Pairs    []PairCount `json:"pairs"`
+}
+
+// PairCount - сколько PR автора ревьюер получал на ревью
+type PairCount struct {
+	AuthorID   string `json:"author_id"`
+	ReviewerID string `json:"reviewer_id"`
+	Count      int    `json:"count"`
+}
@@ -2,6 +2,8 @@ package teams
 
 import (
 	"errors"
+	"io"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tomatoCoderq/avito_task/src/models"
@@ -12,7 +14,14 @@ type ServiceMethods interface {
 	TeamCreate(team *models.Team) (*models.Team, error)
 	TeamGetByName(name string) (*models.Team, error)
 	AddUsersToTeam(teamName string, users []models.User) (*models.Team, error)
-	DeactivateTeamUsersWithPRReassignment(teamName string, userIDs []string) (*models.DeactivationResult, error)
+	DeactivateTeamUsersWithPRReassignment(teamName string, userIDs []string, opts models.DeactivationOptions) (*models.DeactivationResult, error)
+	ReactivateTeamUsers(teamName string, userIDs []string, opts models.ReactivationOptions) (*models.ReactivationResult, error)
+	SetReviewerStrategy(teamName, strategy string) (*models.Team, error)
+	SetTeamLead(teamName, userID string) (*models.Team, error)
+	GetTeamPolicy(teamName string) (*models.TeamPolicy, error)
+	SetTeamPolicy(teamName string, policy *models.TeamPolicy, excludedUserIDs []string, fallbackTeamNames []string) (*models.TeamPolicy, error)
+	GetCodeOwners(teamName string) ([]models.CodeOwnerRule, error)
+	SetCodeOwners(teamName string, r io.Reader) ([]models.CodeOwnerRule, error)
 }
 
 type Controller struct {
@@ -27,8 +36,9 @@ func RegisterController(service ServiceMethods) *Controller {
 
 func (c *Controller) TeamCreate(ctx *gin.Context) {
 	var req struct {
-		TeamName string `json:"team_name" binding:"required"`
-		Members  []struct {
+		TeamName         string `json:"team_name" binding:"required"`
+		ReviewerStrategy string `json:"reviewer_strategy"`
+		Members          []struct {
 			UserID   string `json:"user_id" binding:"required"`
 			Username string `json:"username" binding:"required"`
 			IsActive bool   `json:"is_active"`
@@ -45,10 +55,24 @@ func (c *Controller) TeamCreate(ctx *gin.Context) {
 		return
 	}
 
+	if req.ReviewerStrategy == "" {
+		req.ReviewerStrategy = models.ReviewerStrategyRandom
+	}
+	if !models.IsValidReviewerStrategy(req.ReviewerStrategy) {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "unknown reviewer_strategy",
+			},
+		})
+		return
+	}
+
 	// Создаем команду с пользователями
 	team := &models.Team{
-		Name:  req.TeamName,
-		Users: make([]models.User, len(req.Members)),
+		Name:             req.TeamName,
+		ReviewerStrategy: req.ReviewerStrategy,
+		Users:            make([]models.User, len(req.Members)),
 	}
 
 	for i, member := range req.Members {
@@ -90,8 +114,9 @@ func (c *Controller) TeamCreate(ctx *gin.Context) {
 
 	ctx.JSON(201, gin.H{
 		"team": gin.H{
-			"team_name": createdTeam.Name,
-			"members":   members,
+			"team_name":         createdTeam.Name,
+			"reviewer_strategy": createdTeam.ReviewerStrategy,
+			"members":           members,
 		},
 	})
 }
@@ -138,8 +163,10 @@ func (c *Controller) TeamGetByName(ctx *gin.Context) {
 	}
 
 	ctx.JSON(200, gin.H{
-		"team_name": team.Name,
-		"members":   members,
+		"team_name":         team.Name,
+		"reviewer_strategy": team.ReviewerStrategy,
+		"lead_id":           team.LeadID,
+		"members":           members,
 	})
 }
 
@@ -211,10 +238,64 @@ func (c *Controller) AddUsers(ctx *gin.Context) {
 	})
 }
 
-func (c *Controller) DeactivateUsers(ctx *gin.Context) {
+// SetReviewerStrategy меняет стратегию выбора ревьюверов команды
+func (c *Controller) SetReviewerStrategy(ctx *gin.Context) {
+	var req struct {
+		TeamName         string `json:"team_name" binding:"required"`
+		ReviewerStrategy string `json:"reviewer_strategy" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	if !models.IsValidReviewerStrategy(req.ReviewerStrategy) {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "unknown reviewer_strategy",
+			},
+		})
+		return
+	}
+
+	team, err := c.service.SetReviewerStrategy(req.TeamName, req.ReviewerStrategy)
+	if err != nil {
+		if err.Error() == "team not found" || errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "team not found",
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update team",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"team_name":         team.Name,
+		"reviewer_strategy": team.ReviewerStrategy,
+	})
+}
+
+// SetLead назначает лида команды, которому по умолчанию передаются PR деактивированных участников
+func (c *Controller) SetLead(ctx *gin.Context) {
 	var req struct {
-		TeamName string   `json:"team_name" binding:"required"`
-		UserIDs  []string `json:"user_ids" binding:"required"`
+		TeamName string `json:"team_name" binding:"required"`
+		UserID   string `json:"user_id" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -227,6 +308,304 @@ func (c *Controller) DeactivateUsers(ctx *gin.Context) {
 		return
 	}
 
+	team, err := c.service.SetTeamLead(req.TeamName, req.UserID)
+	if err != nil {
+		if err.Error() == "team not found" || errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "team not found",
+				},
+			})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "NOT_TEAM_MEMBER") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "NOT_TEAM_MEMBER",
+					"message": "user is not a member of the team",
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update team",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"team_name": team.Name,
+		"lead_id":   req.UserID,
+	})
+}
+
+// PolicyGet возвращает политику назначения ревьюверов команды
+func (c *Controller) PolicyGet(ctx *gin.Context) {
+	teamName := ctx.Query("team_name")
+	if teamName == "" {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "team_name query parameter is required",
+			},
+		})
+		return
+	}
+
+	policy, err := c.service.GetTeamPolicy(teamName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "team not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to get team policy",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, policyResponse(teamName, policy))
+}
+
+// PolicySet сохраняет политику назначения ревьюверов команды
+func (c *Controller) PolicySet(ctx *gin.Context) {
+	var req struct {
+		TeamName        string   `json:"team_name" binding:"required"`
+		ReviewerCount   *int     `json:"reviewer_count" binding:"required"`
+		MinApprovals    int      `json:"min_approvals"`
+		AllowCrossTeam  bool     `json:"allow_cross_team"`
+		ExcludedUserIDs []string `json:"excluded_user_ids"`
+		FallbackTeams   []string `json:"fallback_team_names"`
+		SeniorReviewers int      `json:"senior_reviewers"`
+		SeniorityLevel  string   `json:"seniority_level"`
+		SeniorityAction string   `json:"seniority_action"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	policy := &models.TeamPolicy{
+		ReviewerCount:  *req.ReviewerCount,
+		MinApprovals:   req.MinApprovals,
+		AllowCrossTeam: req.AllowCrossTeam,
+
+		SeniorReviewers: req.SeniorReviewers,
+		SeniorityLevel:  req.SeniorityLevel,
+		SeniorityAction: req.SeniorityAction,
+	}
+
+	updated, err := c.service.SetTeamPolicy(req.TeamName, policy, req.ExcludedUserIDs, req.FallbackTeams)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || err.Error() == "user not found" {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "team or user not found",
+				},
+			})
+			return
+		}
+		if strings.Contains(err.Error(), "INVALID_POLICY") {
+			ctx.JSON(400, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_POLICY",
+					"message": strings.TrimPrefix(err.Error(), "INVALID_POLICY: "),
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to set team policy",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, policyResponse(req.TeamName, updated))
+}
+
+// CodeOwnersGet возвращает правила владения кодом команды
+func (c *Controller) CodeOwnersGet(ctx *gin.Context) {
+	teamName := ctx.Query("team_name")
+	if teamName == "" {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "team_name query parameter is required",
+			},
+		})
+		return
+	}
+
+	rules, err := c.service.GetCodeOwners(teamName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "team not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to get code owners",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, codeOwnersResponse(teamName, rules))
+}
+
+// CodeOwnersSet заменяет правила владения кодом команды файлом CODEOWNERS, переданным в поле file.
+// Владельцы в файле указываются как @user_id
+func (c *Controller) CodeOwnersSet(ctx *gin.Context) {
+	teamName := ctx.PostForm("team_name")
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil || teamName == "" {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "team_name and file are required",
+			},
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "cannot read file",
+			},
+		})
+		return
+	}
+	defer file.Close()
+
+	rules, err := c.service.SetCodeOwners(teamName, file)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "team not found",
+				},
+			})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "INVALID_CODEOWNERS") {
+			ctx.JSON(400, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_CODEOWNERS",
+					"message": strings.TrimPrefix(err.Error(), "INVALID_CODEOWNERS: "),
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to set code owners",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, codeOwnersResponse(teamName, rules))
+}
+
+// codeOwnersResponse формирует тело ответа с правилами владения кодом команды
+func codeOwnersResponse(teamName string, rules []models.CodeOwnerRule) gin.H {
+	items := make([]gin.H, 0, len(rules))
+	for _, rule := range rules {
+		ownerIDs := make([]string, 0, len(rule.Owners))
+		for _, owner := range rule.Owners {
+			ownerIDs = append(ownerIDs, owner.ID)
+		}
+		items = append(items, gin.H{
+			"pattern":   rule.Pattern,
+			"owner_ids": ownerIDs,
+		})
+	}
+
+	return gin.H{
+		"team_name": teamName,
+		"rules":     items,
+	}
+}
+
+// policyResponse формирует тело ответа с политикой команды
+func policyResponse(teamName string, policy *models.TeamPolicy) gin.H {
+	excludedUserIDs := make([]string, 0, len(policy.ExcludedUsers))
+	for _, user := range policy.ExcludedUsers {
+		excludedUserIDs = append(excludedUserIDs, user.ID)
+	}
+
+	fallbackTeamNames := make([]string, 0, len(policy.FallbackTeams))
+	for _, fallback := range policy.FallbackTeams {
+		fallbackTeamNames = append(fallbackTeamNames, fallback.FallbackTeam.Name)
+	}
+
+	return gin.H{
+		"policy": gin.H{
+			"team_name":           teamName,
+			"reviewer_count":      policy.ReviewerCount,
+			"min_approvals":       policy.MinApprovals,
+			"allow_cross_team":    policy.AllowCrossTeam,
+			"excluded_user_ids":   excludedUserIDs,
+			"fallback_team_names": fallbackTeamNames,
+			"senior_reviewers":    policy.SeniorReviewers,
+			"seniority_level":     policy.SeniorityLevel,
+			"seniority_action":    policy.SeniorityAction,
+		},
+	}
+}
+
+func (c *Controller) DeactivateUsers(ctx *gin.Context) {
+	var req struct {
+		TeamName         string   `json:"team_name" binding:"required"`
+		UserIDs          []string `json:"user_ids" binding:"required"`
+		DryRun           bool     `json:"dry_run"`
+		AuthoredPRAction string   `json:"authored_prs_action"`
+		TransferTo       string   `json:"transfer_to"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.AuthoredPRAction != "" && !models.IsValidAuthoredPRAction(req.AuthoredPRAction)) {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
 	if len(req.UserIDs) == 0 {
 		ctx.JSON(400, gin.H{
 			"error": gin.H{
@@ -237,8 +616,11 @@ func (c *Controller) DeactivateUsers(ctx *gin.Context) {
 		return
 	}
 
-	
-	result, err := c.service.DeactivateTeamUsersWithPRReassignment(req.TeamName, req.UserIDs)
+	result, err := c.service.DeactivateTeamUsersWithPRReassignment(req.TeamName, req.UserIDs, models.DeactivationOptions{
+		DryRun:           req.DryRun,
+		AuthoredPRAction: req.AuthoredPRAction,
+		TransferTo:       req.TransferTo,
+	})
 	if err != nil {
 		// Обрабатываем различные типы ошибок
 		if err.Error() == "team not found" {
@@ -261,6 +643,16 @@ func (c *Controller) DeactivateUsers(ctx *gin.Context) {
 			return
 		}
 
+		if code, ok := deactivationErrorCode(err); ok {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    code,
+					"message": strings.TrimPrefix(err.Error(), code+": "),
+				},
+			})
+			return
+		}
+
 		ctx.JSON(500, gin.H{
 			"error": gin.H{
 				"code":    "INTERNAL_ERROR",
@@ -272,3 +664,67 @@ func (c *Controller) DeactivateUsers(ctx *gin.Context) {
 
 	ctx.JSON(200, result)
 }
+
+// ReactivateUsers активирует пользователей команды и по запросу возвращает им ревью, переданные при деактивации
+func (c *Controller) ReactivateUsers(ctx *gin.Context) {
+	var req struct {
+		TeamName           string   `json:"team_name" binding:"required"`
+		UserIDs            []string `json:"user_ids" binding:"required"`
+		RestoreAssignments bool     `json:"restore_assignments"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	if len(req.UserIDs) == 0 {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "user_ids cannot be empty",
+			},
+		})
+		return
+	}
+
+	result, err := c.service.ReactivateTeamUsers(req.TeamName, req.UserIDs, models.ReactivationOptions{
+		RestoreAssignments: req.RestoreAssignments,
+	})
+	if err != nil {
+		if err.Error() == "team not found" || errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(404, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Team not found",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to reactivate users",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, result)
+}
+
+// deactivationErrorCode возвращает код конфликта деактивации: обработки PR деактивируемых авторов или правила старшинства
+func deactivationErrorCode(err error) (string, bool) {
+	for _, code := range []string{"NO_TEAM_LEAD", "INVALID_TRANSFER_TARGET", "POLICY_UNSATISFIED"} {
+		if strings.HasPrefix(err.Error(), code) {
+			return code, true
+		}
+	}
+	return "", false
+}
@@ -1,8 +1,13 @@
 package teams
 
 import (
+	"errors"
+	"time"
+
+	"github.com/tomatoCoderq/avito_task/src/internal/storage/sql"
 	"github.com/tomatoCoderq/avito_task/src/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repo struct {
@@ -15,6 +20,13 @@ func NewRepo(db *gorm.DB) *Repo {
 	}
 }
 
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *Repo) WithTx(tx *gorm.DB) RepositoryMethods {
+	return &Repo{
+		db: tx,
+	}
+}
+
 func (r *Repo) TeamExists(name string) (bool, error) {
 	var count int64
 	if err := r.db.Model(&models.Team{}).Where("name = ?", name).Count(&count).Error; err != nil {
@@ -25,8 +37,11 @@ func (r *Repo) TeamExists(name string) (bool, error) {
 
 func (r *Repo) CreateOrUpdateUsers(users []models.User) error {
 	for _, user := range users {
-		// Используем Upsert (создать или обновить)
-		if err := r.db.Save(&user).Error; err != nil {
+		// Upsert обновляет только имя и активность, не затрагивая остальные настройки пользователя
+		if err := r.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "is_active"}),
+		}).Omit("Teams").Create(&user).Error; err != nil {
 			return err
 		}
 	}
@@ -54,6 +69,16 @@ func (r *Repo) TeamGetByName(name string) (*models.Team, error) {
 	return &team, nil
 }
 
+// GetTeamByID получает команду по ID
+func (r *Repo) GetTeamByID(teamID string) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.First(&team, "id = ?", teamID).Error; err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
 func (r *Repo) AddUsersToTeam(teamName string, users []models.User) (*models.Team, error) {
 	var team models.Team
 	if err := r.db.Where("name = ?", teamName).First(&team).Error; err != nil {
@@ -75,6 +100,157 @@ func (r *Repo) AddUsersToTeam(teamName string, users []models.User) (*models.Tea
 	return &team, nil
 }
 
+// SetReviewerStrategy сохраняет стратегию выбора ревьюверов команды
+func (r *Repo) SetReviewerStrategy(teamName, strategy string) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.Where("name = ?", teamName).First(&team).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Model(&team).Update("reviewer_strategy", strategy).Error; err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// GetCodeOwnerRules возвращает правила владения кодом команды с владельцами в порядке файла
+func (r *Repo) GetCodeOwnerRules(teamID string) ([]models.CodeOwnerRule, error) {
+	var rules []models.CodeOwnerRule
+	if err := r.db.
+		Preload("Owners", func(db *gorm.DB) *gorm.DB { return db.Order("users.id") }).
+		Where("team_id = ?", teamID).
+		Order("position").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// SetCodeOwnerRules заменяет правила владения кодом команды
+func (r *Repo) SetCodeOwnerRules(teamID string, rules []models.CodeOwnerRule) ([]models.CodeOwnerRule, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.CodeOwnerRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Omit("Owners.*").Create(&rules).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetCodeOwnerRules(teamID)
+}
+
+// GetUsersByIDs возвращает существующих пользователей из списка
+func (r *Repo) GetUsersByIDs(userIDs []string) ([]models.User, error) {
+	users := []models.User{}
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.Where("id IN ?", userIDs).Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetTeamPolicy возвращает политику команды или политику по умолчанию, если она не задана
+func (r *Repo) GetTeamPolicy(teamID string) (*models.TeamPolicy, error) {
+	var policy models.TeamPolicy
+	err := r.db.
+		Preload("ExcludedUsers").
+		Preload("FallbackTeams", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("FallbackTeams.FallbackTeam").
+		First(&policy, "team_id = ?", teamID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultTeamPolicy(teamID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// SetTeamPolicy сохраняет политику команды вместе со списком исключенных пользователей
+// и упорядоченным списком запасных команд
+func (r *Repo) SetTeamPolicy(policy *models.TeamPolicy, excludedUserIDs []string, fallbackTeamIDs []string) (*models.TeamPolicy, error) {
+	excludedUsers := []models.User{}
+	if len(excludedUserIDs) > 0 {
+		if err := r.db.Where("id IN ?", excludedUserIDs).Find(&excludedUsers).Error; err != nil {
+			return nil, err
+		}
+		if len(excludedUsers) != len(excludedUserIDs) {
+			return nil, errors.New("user not found")
+		}
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("ExcludedUsers", "FallbackTeams").Save(policy).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(policy).Association("ExcludedUsers").Replace(excludedUsers); err != nil {
+			return err
+		}
+
+		if err := tx.Where("team_id = ?", policy.TeamID).Delete(&models.TeamFallback{}).Error; err != nil {
+			return err
+		}
+		if len(fallbackTeamIDs) == 0 {
+			return nil
+		}
+
+		fallbacks := make([]models.TeamFallback, 0, len(fallbackTeamIDs))
+		for i, fallbackTeamID := range fallbackTeamIDs {
+			fallbacks = append(fallbacks, models.TeamFallback{
+				TeamID:         policy.TeamID,
+				FallbackTeamID: fallbackTeamID,
+				Position:       i,
+			})
+		}
+		return tx.Omit("FallbackTeam").Create(&fallbacks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetTeamPolicy(policy.TeamID)
+}
+
+// GetActiveUsersOutsideTeam получает активных пользователей других команд для межкомандного назначения
+func (r *Repo) GetActiveUsersOutsideTeam(teamID string, excludeUserIDs []string, at time.Time) ([]models.User, error) {
+	var users []models.User
+
+	query := r.db.
+		Where("users.is_active = ? AND users.out_of_rotation = ?", true, false).
+		Where(sql.NotAbsent, at, at).
+		Where(sql.NotSnoozed, at).
+		Where("users.id NOT IN (?)", r.db.Table("team_users").Select("user_id").Where("team_id = ?", teamID))
+
+	if len(excludeUserIDs) > 0 {
+		query = query.Where("users.id NOT IN ?", excludeUserIDs)
+	}
+
+	if err := query.Preload("Teams").Order("users.id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// DeactivateUsers деактивирует пользователей независимо от команды
+func (r *Repo) DeactivateUsers(userIDs []string) error {
+	return r.db.Model(&models.User{}).
+		Where("id IN ?", userIDs).
+		Update("is_active", false).Error
+}
+
 // DeactivateUsersInTeam деактивирует пользователей в команде (batch операция)
 func (r *Repo) DeactivateUsersInTeam(teamName string, userIDs []string) error {
 	var team models.Team
@@ -107,13 +283,27 @@ func (r *Repo) DeactivateUsersInTeam(teamName string, userIDs []string) error {
 	return nil
 }
 
-// GetOpenPRsForReviewers получает все открытые PR для указанных ревьюверов
+// LockUsers блокирует строки пользователей до конца транзакции
+func (r *Repo) LockUsers(userIDs []string) error {
+	var lockedIDs []string
+	return r.db.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", userIDs).
+		Order("id").
+		Pluck("id", &lockedIDs).Error
+}
+
+// GetOpenPRsForReviewers получает все открытые PR для указанных ревьюверов.
+// Строки PR блокируются до конца транзакции
 func (r *Repo) GetOpenPRsForReviewers(userIDs []string) ([]models.PR, error) {
 	var prs []models.PR
 
 	err := r.db.
-		Joins("JOIN pr_reviewers ON pr_reviewers.pr_id = prs.id").
-		Where("pr_reviewers.user_id IN ? AND prs.status = 'OPEN'", userIDs).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("prs.id IN (?) AND prs.status = ?",
+			r.db.Table("pr_reviewers").Select("pr_id").Where("user_id IN ?", userIDs),
+			models.PRStatusOpen).
+		Order("prs.id").
 		Preload("Author").
 		Preload("Reviewers").
 		Find(&prs).Error
@@ -126,18 +316,20 @@ func (r *Repo) GetOpenPRsForReviewers(userIDs []string) ([]models.PR, error) {
 }
 
 // GetActiveTeamMembersForReassignment получает активных участников команды для переназначения
-func (r *Repo) GetActiveTeamMembersForReassignment(teamID string, excludeUserIDs []string) ([]models.User, error) {
+func (r *Repo) GetActiveTeamMembersForReassignment(teamID string, excludeUserIDs []string, at time.Time) ([]models.User, error) {
 	var users []models.User
 
 	query := r.db.
 		Joins("JOIN team_users ON team_users.user_id = users.id").
-		Where("team_users.team_id = ? AND users.is_active = ?", teamID, true)
+		Where("team_users.team_id = ? AND users.is_active = ? AND users.out_of_rotation = ?", teamID, true, false).
+		Where(sql.NotAbsent, at, at).
+		Where(sql.NotSnoozed, at)
 
 	if len(excludeUserIDs) > 0 {
 		query = query.Where("users.id NOT IN ?", excludeUserIDs)
 	}
 
-	err := query.Find(&users).Error
+	err := query.Order("users.id").Find(&users).Error
 	if err != nil {
 		return nil, err
 	}
@@ -145,8 +337,13 @@ func (r *Repo) GetActiveTeamMembersForReassignment(teamID string, excludeUserIDs
 	return users, nil
 }
 
+// CountOpenReviews возвращает число открытых PR на ревью у каждого из пользователей
+func (r *Repo) CountOpenReviews(userIDs []string) (map[string]int, error) {
+	return sql.CountOpenReviews(r.db, userIDs)
+}
+
 // BatchReassignReviewers выполняет батчевое переназначение ревьюверов
-func (r *Repo) BatchReassignReviewers(reassignments []models.ReassignmentData) error {
+func (r *Repo) BatchReassignReviewers(reassignments []models.ReassignmentData, at time.Time) error {
 	if len(reassignments) == 0 {
 		return nil
 	}
@@ -160,17 +357,47 @@ func (r *Repo) BatchReassignReviewers(reassignments []models.ReassignmentData) e
 				return err
 			}
 
+			if reassignment.NewReviewerID == "" {
+				continue
+			}
+
 			if err := tx.Exec(
 				"INSERT INTO pr_reviewers (pr_id, user_id) VALUES (?, ?)",
 				reassignment.PRID, reassignment.NewReviewerID,
 			).Error; err != nil {
 				return err
 			}
+
+			if err := sql.RecordAssignments(tx, reassignment.PRID, []string{reassignment.NewReviewerID}, at); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
 }
 
+// MarkPolicyUnsatisfied помечает PR, ревьюверы которых не выполняют правило старшинства политики
+func (r *Repo) MarkPolicyUnsatisfied(prIDs []string) error {
+	if len(prIDs) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.PR{}).
+		Where("id IN ?", prIDs).
+		Update("policy_unsatisfied", true).Error
+}
+
+// MarkNeedMoreReviewers помечает PR, которым не хватает ревьюверов
+func (r *Repo) MarkNeedMoreReviewers(prIDs []string) error {
+	if len(prIDs) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.PR{}).
+		Where("id IN ?", prIDs).
+		Update("need_more_reviewers", true).Error
+}
+
 // ValidateUsersInTeam проверяет, что все указанные пользователи состоят в команде
 func (r *Repo) ValidateUsersInTeam(teamName string, userIDs []string) ([]string, error) {
 	var team models.Team
@@ -191,3 +418,215 @@ func (r *Repo) ValidateUsersInTeam(teamName string, userIDs []string) ([]string,
 
 	return existingUserIDs, nil
 }
+
+// SetTeamLead назначает лида команды
+func (r *Repo) SetTeamLead(teamName, userID string) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.Where("name = ?", teamName).First(&team).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Model(&team).Update("lead_id", userID).Error; err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// GetUserByID получает пользователя вместе с его командами
+func (r *Repo) GetUserByID(userID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Preload("Teams").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetOpenPRsByAuthors получает незавершенные PR (открытые и черновики) указанных авторов.
+// Строки PR блокируются до конца транзакции
+func (r *Repo) GetOpenPRsByAuthors(authorIDs []string) ([]models.PR, error) {
+	var prs []models.PR
+
+	err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("author_id IN ? AND status IN ?", authorIDs, []string{models.PRStatusOpen, models.PRStatusDraft}).
+		Order("id").
+		Preload("Reviewers").
+		Find(&prs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+// TransferPRs передает PR новому автору. Если новый автор был ревьювером PR, он снимается с ревью
+func (r *Repo) TransferPRs(prIDs []string, newAuthorID string, at time.Time) error {
+	if len(prIDs) == 0 {
+		return nil
+	}
+
+	if err := r.db.Model(&models.PR{}).
+		Where("id IN ?", prIDs).
+		Updates(map[string]interface{}{
+			"author_id":       newAuthorID,
+			"author_inactive": false,
+			"updated_at":      at,
+		}).Error; err != nil {
+		return err
+	}
+
+	return r.db.
+		Exec("DELETE FROM pr_reviewers WHERE pr_id IN ? AND user_id = ?", prIDs, newAuthorID).Error
+}
+
+// ClosePRs закрывает PR с проверкой допустимости перехода, как UpdateStatus. Строки PR блокируются на время операции
+func (r *Repo) ClosePRs(prIDs []string, at time.Time) error {
+	if len(prIDs) == 0 {
+		return nil
+	}
+
+	var prs []models.PR
+	if err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", prIDs).
+		Find(&prs).Error; err != nil {
+		return err
+	}
+
+	closeIDs := make([]string, 0, len(prs))
+	for _, pr := range prs {
+		if pr.Status == models.PRStatusClosed {
+			continue
+		}
+		if err := models.CheckTransition(pr.Status, models.PRStatusClosed); err != nil {
+			return err
+		}
+		closeIDs = append(closeIDs, pr.ID)
+	}
+	if len(closeIDs) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.PR{}).
+		Where("id IN ?", closeIDs).
+		Updates(map[string]interface{}{
+			"status":     models.PRStatusClosed,
+			"updated_at": at,
+		}).Error
+}
+
+// MarkAuthorInactive помечает PR, автор которых деактивирован
+func (r *Repo) MarkAuthorInactive(prIDs []string, at time.Time) error {
+	if len(prIDs) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.PR{}).
+		Where("id IN ?", prIDs).
+		Updates(map[string]interface{}{
+			"author_inactive": true,
+			"updated_at":      at,
+		}).Error
+}
+
+// SaveDeactivation сохраняет партию деактивации вместе с выполненными заменами ревьюверов
+func (r *Repo) SaveDeactivation(deactivation *models.Deactivation) error {
+	return r.db.Omit("Users.*").Create(deactivation).Error
+}
+
+// ActivateUsers активирует пользователей, возвращает их в ротацию и снимает пометку с оставленных ими PR
+func (r *Repo) ActivateUsers(userIDs []string) error {
+	if err := r.db.Model(&models.User{}).
+		Where("id IN ?", userIDs).
+		Updates(map[string]interface{}{
+			"is_active":       true,
+			"out_of_rotation": false,
+		}).Error; err != nil {
+		return err
+	}
+
+	return r.db.Model(&models.PR{}).
+		Where("author_id IN ? AND author_inactive = ?", userIDs, true).
+		Update("author_inactive", false).Error
+}
+
+// GetPendingReassignments получает необработанные замены ревьюверов из деактиваций команды.
+// Строки замен блокируются до конца транзакции, чтобы параллельная реактивация не обработала их повторно
+func (r *Repo) GetPendingReassignments(teamID string, userIDs []string) ([]models.DeactivationReassignment, error) {
+	var reassignments []models.DeactivationReassignment
+
+	err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "deactivation_reassignments"}}).
+		Joins("JOIN deactivations ON deactivations.id = deactivation_reassignments.deactivation_id").
+		Where("deactivations.team_id = ? AND deactivation_reassignments.from_reviewer IN ? AND deactivation_reassignments.resolved_at IS NULL",
+			teamID, userIDs).
+		Order("deactivation_reassignments.id").
+		Find(&reassignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return reassignments, nil
+}
+
+// GetPRsForUpdate получает PR вместе с ревьюверами. Строки PR блокируются до конца транзакции
+func (r *Repo) GetPRsForUpdate(prIDs []string) ([]models.PR, error) {
+	var prs []models.PR
+	if len(prIDs) == 0 {
+		return prs, nil
+	}
+
+	err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", prIDs).
+		Order("id").
+		Preload("Reviewers").
+		Find(&prs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+// GetSubmittedReviews возвращает для каждого PR множество пользователей, оставивших по нему ревью
+func (r *Repo) GetSubmittedReviews(prIDs []string) (map[string]map[string]bool, error) {
+	var rows []struct {
+		PRID       string
+		ReviewerID string
+	}
+
+	submitted := make(map[string]map[string]bool)
+	if len(prIDs) == 0 {
+		return submitted, nil
+	}
+
+	if err := r.db.Model(&models.PRReview{}).
+		Distinct("pr_id", "reviewer_id").
+		Where("pr_id IN ?", prIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if submitted[row.PRID] == nil {
+			submitted[row.PRID] = make(map[string]bool)
+		}
+		submitted[row.PRID][row.ReviewerID] = true
+	}
+
+	return submitted, nil
+}
+
+// ResolveReassignments отмечает замены ревьюверов как обработанные
+func (r *Repo) ResolveReassignments(ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.DeactivationReassignment{}).
+		Where("id IN ?", ids).
+		Update("resolved_at", at).Error
+}
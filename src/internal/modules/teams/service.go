@@ -2,30 +2,84 @@ package teams
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/tomatoCoderq/avito_task/src/models"
+	"gorm.io/gorm"
 )
 
 type RepositoryMethods interface {
 	TeamCreate(team *models.Team) (*models.Team, error)
 	TeamGetByName(name string) (*models.Team, error)
+	GetTeamByID(teamID string) (*models.Team, error)
 	TeamExists(name string) (bool, error)
 	CreateOrUpdateUsers(users []models.User) error
 	AddUsersToTeam(teamName string, users []models.User) (*models.Team, error)
 	DeactivateUsersInTeam(teamName string, userIDs []string) error
 	GetOpenPRsForReviewers(userIDs []string) ([]models.PR, error)
-	GetActiveTeamMembersForReassignment(teamID string, excludeUserIDs []string) ([]models.User, error)
-	BatchReassignReviewers(reassignments []models.ReassignmentData) error
+	GetActiveTeamMembersForReassignment(teamID string, excludeUserIDs []string, at time.Time) ([]models.User, error)
+	BatchReassignReviewers(reassignments []models.ReassignmentData, at time.Time) error
 	ValidateUsersInTeam(teamName string, userIDs []string) ([]string, error)
+	SetReviewerStrategy(teamName, strategy string) (*models.Team, error)
+	GetTeamPolicy(teamID string) (*models.TeamPolicy, error)
+	SetTeamPolicy(policy *models.TeamPolicy, excludedUserIDs []string, fallbackTeamIDs []string) (*models.TeamPolicy, error)
+	GetActiveUsersOutsideTeam(teamID string, excludeUserIDs []string, at time.Time) ([]models.User, error)
+	MarkNeedMoreReviewers(prIDs []string) error
+	MarkPolicyUnsatisfied(prIDs []string) error
+	CountOpenReviews(userIDs []string) (map[string]int, error)
+	LockUsers(userIDs []string) error
+	SetTeamLead(teamName, userID string) (*models.Team, error)
+	GetUserByID(userID string) (*models.User, error)
+	GetOpenPRsByAuthors(authorIDs []string) ([]models.PR, error)
+	TransferPRs(prIDs []string, newAuthorID string, at time.Time) error
+	ClosePRs(prIDs []string, at time.Time) error
+	MarkAuthorInactive(prIDs []string, at time.Time) error
+	DeactivateUsers(userIDs []string) error
+	SaveDeactivation(deactivation *models.Deactivation) error
+	ActivateUsers(userIDs []string) error
+	GetPendingReassignments(teamID string, userIDs []string) ([]models.DeactivationReassignment, error)
+	GetPRsForUpdate(prIDs []string) ([]models.PR, error)
+	GetSubmittedReviews(prIDs []string) (map[string]map[string]bool, error)
+	ResolveReassignments(ids []uint, at time.Time) error
+	GetCodeOwnerRules(teamID string) ([]models.CodeOwnerRule, error)
+	SetCodeOwnerRules(teamID string, rules []models.CodeOwnerRule) ([]models.CodeOwnerRule, error)
+	GetUsersByIDs(userIDs []string) ([]models.User, error)
+	WithTx(tx *gorm.DB) RepositoryMethods
+}
+
+// UnitOfWork выполняет операции нескольких репозиториев в одной транзакции
+type UnitOfWork interface {
+	Do(fn func(tx *gorm.DB) error) error
+}
+
+// ReviewerSweeper запускает доназначение ревьюверов в PR, которым их не хватает
+type ReviewerSweeper interface {
+	Trigger()
+}
+
+// Clock возвращает текущее время
+type Clock interface {
+	Now() time.Time
 }
 
 type Service struct {
-	repo RepositoryMethods
+	repo    RepositoryMethods
+	uow     UnitOfWork
+	sweeper ReviewerSweeper
+	clock   Clock
 }
 
-func RegisterService(repo RepositoryMethods) *Service {
+// RegisterService создает сервис команд. clock задает время, по которому отбираются кандидаты
+// и записываются назначения; равнозагруженные кандидаты выбираются в порядке ID, поэтому деактивация воспроизводима
+func RegisterService(repo RepositoryMethods, uow UnitOfWork, sweeper ReviewerSweeper, clock Clock) *Service {
 	return &Service{
-		repo: repo,
+		repo:    repo,
+		uow:     uow,
+		sweeper: sweeper,
+		clock:   clock,
 	}
 }
 
@@ -59,24 +113,208 @@ func (s *Service) AddUsersToTeam(teamName string, users []models.User) (*models.
 		return nil, errors.New("team not found")
 	}
 
-	return s.repo.AddUsersToTeam(teamName, users)
+	team, err := s.repo.AddUsersToTeam(teamName, users)
+	if err != nil {
+		return nil, err
+	}
+
+	// Новые активные участники могут закрыть нехватку ревьюверов в открытых PR
+	s.sweeper.Trigger()
+
+	return team, nil
 }
 
-// DeactivateTeamUsersWithPRReassignment деактивирует пользователей команды и переназначает их PR
-func (s *Service) DeactivateTeamUsersWithPRReassignment(teamName string, userIDs []string) (*models.DeactivationResult, error) {
+// SetReviewerStrategy задает стратегию выбора ревьюверов для команды
+func (s *Service) SetReviewerStrategy(teamName, strategy string) (*models.Team, error) {
+	exists, err := s.repo.TeamExists(teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("team not found")
+	}
+
+	return s.repo.SetReviewerStrategy(teamName, strategy)
+}
+
+// SetTeamLead назначает лида команды. Лид должен быть участником команды
+func (s *Service) SetTeamLead(teamName, userID string) (*models.Team, error) {
+	exists, err := s.repo.TeamExists(teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("team not found")
+	}
+
+	members, err := s.repo.ValidateUsersInTeam(teamName, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("NOT_TEAM_MEMBER: user is not a member of the team")
+	}
+
+	return s.repo.SetTeamLead(teamName, userID)
+}
+
+// GetTeamPolicy возвращает политику назначения ревьюверов команды
+func (s *Service) GetTeamPolicy(teamName string) (*models.TeamPolicy, error) {
+	team, err := s.repo.TeamGetByName(teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetTeamPolicy(team.ID)
+}
+
+// GetCodeOwners возвращает правила владения кодом команды в порядке файла CODEOWNERS
+func (s *Service) GetCodeOwners(teamName string) ([]models.CodeOwnerRule, error) {
+	team, err := s.repo.TeamGetByName(teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetCodeOwnerRules(team.ID)
+}
+
+// SetCodeOwners заменяет правила владения кодом команды содержимым файла CODEOWNERS.
+// Все владельцы должны быть существующими пользователями
+func (s *Service) SetCodeOwners(teamName string, r io.Reader) ([]models.CodeOwnerRule, error) {
+	team, err := s.repo.TeamGetByName(teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := models.ParseCodeOwners(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerLists := make([][]string, len(entries))
+	for i, entry := range entries {
+		ownerLists[i] = entry.OwnerIDs
+	}
+	ownerIDs := mergeIDs(ownerLists...)
+
+	owners, err := s.repo.GetUsersByIDs(ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	usersByID := make(map[string]models.User, len(owners))
+	for _, owner := range owners {
+		usersByID[owner.ID] = owner
+	}
+
+	rules := make([]models.CodeOwnerRule, 0, len(entries))
+	for i, entry := range entries {
+		rule := models.CodeOwnerRule{
+			TeamID:   team.ID,
+			Position: i,
+			Pattern:  entry.Pattern,
+			Owners:   make([]models.User, 0, len(entry.OwnerIDs)),
+		}
+		for _, ownerID := range entry.OwnerIDs {
+			owner, ok := usersByID[ownerID]
+			if !ok {
+				return nil, fmt.Errorf("INVALID_CODEOWNERS: line %d: unknown owner @%s", entry.Line, ownerID)
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+		rules = append(rules, rule)
+	}
+
+	return s.repo.SetCodeOwnerRules(team.ID, rules)
+}
+
+// SetTeamPolicy сохраняет политику назначения ревьюверов команды.
+// Запасные команды задаются по имени в порядке, в котором в них ищутся ревьюверы
+func (s *Service) SetTeamPolicy(teamName string, policy *models.TeamPolicy, excludedUserIDs []string, fallbackTeamNames []string) (*models.TeamPolicy, error) {
+	if policy.ReviewerCount < 0 || policy.MinApprovals < 0 || policy.MinApprovals > policy.ReviewerCount {
+		return nil, errors.New("INVALID_POLICY: min_approvals must be between 0 and reviewer_count")
+	}
+	if policy.SeniorReviewers < 0 || policy.SeniorReviewers > policy.ReviewerCount {
+		return nil, errors.New("INVALID_POLICY: senior_reviewers must be between 0 and reviewer_count")
+	}
+	if policy.SeniorityLevel == "" {
+		policy.SeniorityLevel = models.SenioritySenior
+	}
+	if !models.IsValidSeniority(policy.SeniorityLevel) {
+		return nil, errors.New("INVALID_POLICY: unknown seniority_level " + policy.SeniorityLevel)
+	}
+	if policy.SeniorityAction == "" {
+		policy.SeniorityAction = models.SeniorityActionFail
+	}
+	if !models.IsValidSeniorityAction(policy.SeniorityAction) {
+		return nil, errors.New("INVALID_POLICY: seniority_action must be fail or flag")
+	}
+
+	team, err := s.repo.TeamGetByName(teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackTeamIDs := make([]string, 0, len(fallbackTeamNames))
+	seen := make(map[string]bool, len(fallbackTeamNames))
+	for _, name := range fallbackTeamNames {
+		if name == teamName {
+			return nil, errors.New("INVALID_POLICY: team cannot be its own fallback")
+		}
+		if seen[name] {
+			return nil, errors.New("INVALID_POLICY: fallback team " + name + " is listed twice")
+		}
+		seen[name] = true
+
+		fallbackTeam, err := s.repo.TeamGetByName(name)
+		if err != nil {
+			return nil, err
+		}
+		fallbackTeamIDs = append(fallbackTeamIDs, fallbackTeam.ID)
+	}
+
+	policy.TeamID = team.ID
+	return s.repo.SetTeamPolicy(policy, excludedUserIDs, fallbackTeamIDs)
+}
+
+// DeactivateTeamUsersWithPRReassignment деактивирует пользователей команды и переназначает их PR.
+// Вся операция выполняется в одной транзакции. В режиме opts.DryRun возвращает план переназначений, ничего не изменяя
+func (s *Service) DeactivateTeamUsersWithPRReassignment(teamName string, userIDs []string, opts models.DeactivationOptions) (*models.DeactivationResult, error) {
+	var result *models.DeactivationResult
+
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.deactivate(s.repo.WithTx(tx), teamName, userIDs, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// deactivate выполняет деактивацию через репозиторий, привязанный к транзакции
+func (s *Service) deactivate(repo RepositoryMethods, teamName string, userIDs []string, opts models.DeactivationOptions) (*models.DeactivationResult, error) {
 	result := &models.DeactivationResult{
-		DeactivatedUsers: []string{},
-		ReassignedPRs:    []models.PRReassignmentInfo{},
-		Errors:           []string{},
+		DryRun:               opts.DryRun,
+		DeactivatedUsers:     []string{},
+		ReassignedPRs:        []models.PRReassignmentInfo{},
+		UnderstaffedPRs:      []string{},
+		UncoveredPRs:         []string{},
+		AtCapacityPRs:        []string{},
+		PolicyUnsatisfiedPRs: []string{},
+		CandidateLoad:        map[string]int{},
+		AuthoredPRs:          []models.AuthoredPRInfo{},
+		Errors:               []string{},
 	}
 
-	if exists, err := s.repo.TeamExists(teamName); err != nil {
+	if exists, err := repo.TeamExists(teamName); err != nil {
 		return nil, err
 	} else if !exists {
 		return nil, errors.New("team not found")
 	}
 
-	validUserIDs, err := s.repo.ValidateUsersInTeam(teamName, userIDs)
+	validUserIDs, err := repo.ValidateUsersInTeam(teamName, userIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -95,89 +333,814 @@ func (s *Service) DeactivateTeamUsersWithPRReassignment(teamName string, userIDs
 		return result, nil
 	}
 
-	team, err := s.repo.TeamGetByName(teamName)
+	team, err := repo.TeamGetByName(teamName)
 	if err != nil {
 		return nil, err
 	}
 
-	openPRs, err := s.repo.GetOpenPRsForReviewers(validUserIDs)
-	if err != nil {
+	// Блокируем деактивируемых пользователей, чтобы параллельное создание PR не назначило их ревьюверами,
+	// а затем строки затронутых PR
+	if err := repo.LockUsers(validUserIDs); err != nil {
 		return nil, err
 	}
 
-	excludeUserIDs := append(validUserIDs, s.extractAuthorIDs(openPRs)...)
-	activeCandidates, err := s.repo.GetActiveTeamMembersForReassignment(team.ID, excludeUserIDs)
+	openPRs, err := repo.GetOpenPRsForReviewers(validUserIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	reassignments, reassignmentInfos := s.prepareReassignments(openPRs, validUserIDs, activeCandidates)
+	authored, err := s.planAuthoredPRs(repo, team, validUserIDs, opts)
+	if err != nil {
+		return nil, err
+	}
+	openPRs = applyAuthoredPlan(openPRs, authored)
 
-	if err := s.repo.DeactivateUsersInTeam(teamName, validUserIDs); err != nil {
+	groups, err := s.groupByTeam(repo, team, openPRs)
+	if err != nil {
 		return nil, err
 	}
 
-	if len(reassignments) > 0 {
-		if err := s.repo.BatchReassignReviewers(reassignments); err != nil {
+	plan := ReassignmentPlan{
+		Reassignments:     []models.ReassignmentData{},
+		Infos:             []models.PRReassignmentInfo{},
+		UnderstaffedPRIDs: []string{},
+		AtCapacityPRIDs:   []string{},
+		CandidateLoad:     map[string]int{},
+
+		PolicyUnsatisfiedPRIDs: []string{},
+	}
+	// PR, которые по политике своей команды нельзя оставить без старших ревьюверов
+	failedPRIDs := []string{}
+
+	for _, group := range groups {
+		// Авторов PR и текущих ревьюверов не исключаем глобально: это делается отдельно для каждого PR
+		excludeUserIDs := append([]string{}, validUserIDs...)
+		for _, user := range group.Policy.ExcludedUsers {
+			excludeUserIDs = append(excludeUserIDs, user.ID)
+		}
+
+		pools, err := s.candidatePools(repo, group.Team, group.Policy, excludeUserIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		// Нагрузка общая для всех команд, чтобы один кандидат не получил лишние ревью из разных команд
+		load, err := s.currentLoad(repo, pools)
+		if err != nil {
 			return nil, err
 		}
+		for candidateID, count := range load {
+			if _, ok := plan.CandidateLoad[candidateID]; !ok {
+				plan.CandidateLoad[candidateID] = count
+			}
+		}
+
+		groupPlan := s.prepareReassignments(group.PRs, validUserIDs, group.Policy, pools, plan.CandidateLoad)
+		plan.Reassignments = append(plan.Reassignments, groupPlan.Reassignments...)
+		plan.Infos = append(plan.Infos, groupPlan.Infos...)
+		plan.UnderstaffedPRIDs = append(plan.UnderstaffedPRIDs, groupPlan.UnderstaffedPRIDs...)
+		plan.AtCapacityPRIDs = append(plan.AtCapacityPRIDs, groupPlan.AtCapacityPRIDs...)
+		plan.PolicyUnsatisfiedPRIDs = append(plan.PolicyUnsatisfiedPRIDs, groupPlan.PolicyUnsatisfiedPRIDs...)
+		if group.Policy.SeniorityAction == models.SeniorityActionFail {
+			failedPRIDs = append(failedPRIDs, groupPlan.PolicyUnsatisfiedPRIDs...)
+		}
 	}
 
 	result.DeactivatedUsers = validUserIDs
-	result.ReassignedPRs = reassignmentInfos
+	result.ReassignedPRs = plan.Infos
+	result.UnderstaffedPRs = mergeIDs(plan.UnderstaffedPRIDs, authored.UnderstaffedPRIDs)
+	result.UncoveredPRs = s.uncoveredPRs(openPRs, validUserIDs, plan.Reassignments)
+	result.AtCapacityPRs = plan.AtCapacityPRIDs
+	result.PolicyUnsatisfiedPRs = plan.PolicyUnsatisfiedPRIDs
+	result.CandidateLoad = plan.CandidateLoad
+	result.AuthoredPRs = authored.Infos
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if len(failedPRIDs) > 0 {
+		return nil, fmt.Errorf("POLICY_UNSATISFIED: no replacement with required seniority for PRs %s",
+			strings.Join(failedPRIDs, ", "))
+	}
+
+	if !opts.KeepActive {
+		if err := repo.DeactivateUsersInTeam(teamName, validUserIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.applyAuthoredPRs(repo, authored); err != nil {
+		return nil, err
+	}
+
+	if len(plan.Reassignments) > 0 {
+		if err := repo.BatchReassignReviewers(plan.Reassignments, s.clock.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := repo.MarkNeedMoreReviewers(result.UnderstaffedPRs); err != nil {
+		return nil, err
+	}
+
+	if err := repo.MarkPolicyUnsatisfied(result.PolicyUnsatisfiedPRs); err != nil {
+		return nil, err
+	}
+
+	// Сохраняем партию, чтобы при реактивации можно было вернуть ревью
+	deactivation := &models.Deactivation{
+		TeamID:        team.ID,
+		Users:         make([]models.User, 0, len(validUserIDs)),
+		Reassignments: make([]models.DeactivationReassignment, 0, len(plan.Infos)),
+	}
+	for _, userID := range validUserIDs {
+		deactivation.Users = append(deactivation.Users, models.User{ID: userID})
+	}
+	for _, info := range plan.Infos {
+		deactivation.Reassignments = append(deactivation.Reassignments, models.DeactivationReassignment{
+			PRID:            info.PRID,
+			FromReviewer:    info.FromReviewer,
+			ToReviewer:      info.ToReviewer,
+			ReplacementTeam: info.ReplacementTeam,
+		})
+	}
+	if err := repo.SaveDeactivation(deactivation); err != nil {
+		return nil, err
+	}
+	result.DeactivationID = deactivation.ID
+
+	return result, nil
+}
+
+// ReactivateTeamUsers активирует пользователей команды. С opts.RestoreAssignments пользователям возвращаются ревью,
+// переданные другим при деактивации, если PR еще открыт и временный ревьювер не успел оставить ревью
+func (s *Service) ReactivateTeamUsers(teamName string, userIDs []string, opts models.ReactivationOptions) (*models.ReactivationResult, error) {
+	var result *models.ReactivationResult
+
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.reactivate(s.repo.WithTx(tx), teamName, userIDs, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Вернувшиеся пользователи могут закрыть нехватку ревьюверов в открытых PR
+	if len(result.ReactivatedUsers) > 0 {
+		s.sweeper.Trigger()
+	}
+
+	return result, nil
+}
+
+// reactivate выполняет реактивацию через репозиторий, привязанный к транзакции
+func (s *Service) reactivate(repo RepositoryMethods, teamName string, userIDs []string, opts models.ReactivationOptions) (*models.ReactivationResult, error) {
+	result := &models.ReactivationResult{
+		ReactivatedUsers: []string{},
+		RestoredPRs:      []models.PRReassignmentInfo{},
+		SkippedPRs:       []models.RestoreSkipInfo{},
+		Errors:           []string{},
+	}
+
+	team, err := repo.TeamGetByName(teamName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("team not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	validUserIDs, err := repo.ValidateUsersInTeam(teamName, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	validUserMap := make(map[string]bool)
+	for _, id := range validUserIDs {
+		validUserMap[id] = true
+	}
+	for _, id := range userIDs {
+		if !validUserMap[id] {
+			result.Errors = append(result.Errors, "user "+id+" is not in team "+teamName)
+		}
+	}
+
+	if len(validUserIDs) == 0 {
+		return result, nil
+	}
+
+	if err := repo.LockUsers(validUserIDs); err != nil {
+		return nil, err
+	}
+
+	if err := repo.ActivateUsers(validUserIDs); err != nil {
+		return nil, err
+	}
+	result.ReactivatedUsers = validUserIDs
+
+	pending, err := repo.GetPendingReassignments(team.ID, validUserIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.RestoreAssignments && len(pending) > 0 {
+		swaps, err := s.prepareRestore(repo, team.Name, pending, result)
+		if err != nil {
+			return nil, err
+		}
+		if err := repo.BatchReassignReviewers(swaps, s.clock.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Необработанные замены больше не откатываются: после реактивации они теряют смысл
+	resolvedIDs := make([]uint, 0, len(pending))
+	for _, reassignment := range pending {
+		resolvedIDs = append(resolvedIDs, reassignment.ID)
+	}
+	if err := repo.ResolveReassignments(resolvedIDs, s.clock.Now()); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
 
-// extractAuthorIDs извлекает ID авторов из списка PR
-func (s *Service) extractAuthorIDs(prs []models.PR) []string {
-	authorMap := make(map[string]bool)
+// prepareRestore определяет, какие замены ревьюверов можно откатить, и дополняет результат.
+// Замены просматриваются от старых к новым, поэтому при повторных деактивациях учитывается цепочка замен
+func (s *Service) prepareRestore(repo RepositoryMethods, teamName string, pending []models.DeactivationReassignment, result *models.ReactivationResult) ([]models.ReassignmentData, error) {
+	prIDs := make([]string, 0, len(pending))
+	seen := make(map[string]bool)
+	for _, reassignment := range pending {
+		if !seen[reassignment.PRID] {
+			seen[reassignment.PRID] = true
+			prIDs = append(prIDs, reassignment.PRID)
+		}
+	}
+
+	prs, err := repo.GetPRsForUpdate(prIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	submitted, err := repo.GetSubmittedReviews(prIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	prsByID := make(map[string]models.PR, len(prs))
+	assigned := make(map[string]map[string]bool, len(prs))
+	for _, pr := range prs {
+		prsByID[pr.ID] = pr
+		assigned[pr.ID] = make(map[string]bool, len(pr.Reviewers))
+		for _, reviewer := range pr.Reviewers {
+			assigned[pr.ID][reviewer.ID] = true
+		}
+	}
+
+	swaps := []models.ReassignmentData{}
+	for _, reassignment := range pending {
+		pr, ok := prsByID[reassignment.PRID]
+
+		reason := ""
+		switch {
+		case !ok || pr.Status != models.PRStatusOpen:
+			reason = models.RestoreSkipPRNotOpen
+		case pr.AuthorID == reassignment.FromReviewer:
+			reason = models.RestoreSkipAuthor
+		case assigned[pr.ID][reassignment.FromReviewer]:
+			reason = models.RestoreSkipAlreadyAssigned
+		case !assigned[pr.ID][reassignment.ToReviewer]:
+			reason = models.RestoreSkipReviewerChanged
+		case submitted[pr.ID][reassignment.ToReviewer]:
+			reason = models.RestoreSkipReviewSubmitted
+		}
+
+		if reason != "" {
+			result.SkippedPRs = append(result.SkippedPRs, models.RestoreSkipInfo{
+				PRID:              reassignment.PRID,
+				Reviewer:          reassignment.FromReviewer,
+				TemporaryReviewer: reassignment.ToReviewer,
+				Reason:            reason,
+			})
+			continue
+		}
+
+		delete(assigned[pr.ID], reassignment.ToReviewer)
+		assigned[pr.ID][reassignment.FromReviewer] = true
+
+		swaps = append(swaps, models.ReassignmentData{
+			PRID:          pr.ID,
+			OldReviewerID: reassignment.ToReviewer,
+			NewReviewerID: reassignment.FromReviewer,
+		})
+		result.RestoredPRs = append(result.RestoredPRs, models.PRReassignmentInfo{
+			PRID:            pr.ID,
+			FromReviewer:    reassignment.ToReviewer,
+			ToReviewer:      reassignment.FromReviewer,
+			ReplacementTeam: teamName,
+		})
+	}
+
+	return swaps, nil
+}
+
+// DeactivateUser деактивирует одного пользователя с переназначением его ревью.
+// Кандидаты и политика берутся из основной команды пользователя, ей же принадлежит лид для передачи PR
+func (s *Service) DeactivateUser(userID string, opts models.DeactivationOptions) (*models.DeactivationResult, error) {
+	var result *models.DeactivationResult
+
+	err := s.uow.Do(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+
+		user, err := repo.GetUserByID(userID)
+		if err != nil {
+			return err
+		}
+
+		if team, ok := user.PrimaryTeam(); ok {
+			result, err = s.deactivate(repo, team.Name, []string{userID}, opts)
+			return err
+		}
+
+		// Пользователь вне команд не может быть ревьювером, остается обработать только его PR
+		result, err = s.deactivateTeamless(repo, userID, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// deactivateTeamless деактивирует пользователя, не состоящего ни в одной команде
+func (s *Service) deactivateTeamless(repo RepositoryMethods, userID string, opts models.DeactivationOptions) (*models.DeactivationResult, error) {
+	result := &models.DeactivationResult{
+		DryRun:               opts.DryRun,
+		DeactivatedUsers:     []string{userID},
+		ReassignedPRs:        []models.PRReassignmentInfo{},
+		UnderstaffedPRs:      []string{},
+		UncoveredPRs:         []string{},
+		AtCapacityPRs:        []string{},
+		PolicyUnsatisfiedPRs: []string{},
+		CandidateLoad:        map[string]int{},
+		Errors:               []string{},
+	}
+
+	if err := repo.LockUsers([]string{userID}); err != nil {
+		return nil, err
+	}
+
+	authored, err := s.planAuthoredPRs(repo, nil, []string{userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.AuthoredPRs = authored.Infos
+	result.UnderstaffedPRs = authored.UnderstaffedPRIDs
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if !opts.KeepActive {
+		if err := repo.DeactivateUsers([]string{userID}); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, s.applyAuthoredPRs(repo, authored)
+}
+
+// planAuthoredPRs определяет, что произойдет с незавершенными PR деактивируемых авторов
+func (s *Service) planAuthoredPRs(repo RepositoryMethods, team *models.Team, userIDs []string, opts models.DeactivationOptions) (AuthoredPRPlan, error) {
+	plan := AuthoredPRPlan{
+		Action:            opts.AuthoredPRAction,
+		PRIDs:             []string{},
+		Infos:             []models.AuthoredPRInfo{},
+		UnderstaffedPRIDs: []string{},
+	}
+
+	if opts.AuthoredPRAction == "" {
+		return plan, nil
+	}
+
+	prs, err := repo.GetOpenPRsByAuthors(userIDs)
+	if err != nil {
+		return plan, err
+	}
+	if len(prs) == 0 {
+		return plan, nil
+	}
+
+	if opts.AuthoredPRAction == models.AuthoredPRActionTransfer {
+		plan.NewAuthorID, err = s.transferTarget(repo, team, userIDs, opts.TransferTo)
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	for _, pr := range prs {
+		plan.PRIDs = append(plan.PRIDs, pr.ID)
+		plan.Infos = append(plan.Infos, models.AuthoredPRInfo{
+			PRID:        pr.ID,
+			AuthorID:    pr.AuthorID,
+			Action:      opts.AuthoredPRAction,
+			NewAuthorID: plan.NewAuthorID,
+		})
+
+		// Автор не может ревьюить свой PR, поэтому новый автор снимается с ревью
+		if plan.NewAuthorID != "" && pr.Status == models.PRStatusOpen && isAssigned(pr, plan.NewAuthorID) {
+			plan.UnderstaffedPRIDs = append(plan.UnderstaffedPRIDs, pr.ID)
+		}
+	}
+
+	return plan, nil
+}
+
+// transferTarget возвращает нового автора PR: явно заданного пользователя или лида команды
+func (s *Service) transferTarget(repo RepositoryMethods, team *models.Team, deactivatedUserIDs []string, transferTo string) (string, error) {
+	target := transferTo
+	if target == "" {
+		if team == nil || team.LeadID == "" {
+			return "", errors.New("NO_TEAM_LEAD: team has no lead to transfer PRs to")
+		}
+		target = team.LeadID
+	}
+
+	for _, userID := range deactivatedUserIDs {
+		if userID == target {
+			return "", errors.New("INVALID_TRANSFER_TARGET: user is being deactivated")
+		}
+	}
+
+	// Блокируем нового автора, чтобы его не деактивировали параллельно
+	if err := repo.LockUsers([]string{target}); err != nil {
+		return "", err
+	}
+
+	user, err := repo.GetUserByID(target)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errors.New("INVALID_TRANSFER_TARGET: user not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	if !user.IsActive {
+		return "", errors.New("INVALID_TRANSFER_TARGET: user is not active")
+	}
+
+	return target, nil
+}
+
+// applyAuthoredPRs записывает в БД план обработки PR деактивируемых авторов
+func (s *Service) applyAuthoredPRs(repo RepositoryMethods, plan AuthoredPRPlan) error {
+	switch plan.Action {
+	case models.AuthoredPRActionTransfer:
+		if err := repo.TransferPRs(plan.PRIDs, plan.NewAuthorID, s.clock.Now()); err != nil {
+			return err
+		}
+		return repo.MarkNeedMoreReviewers(plan.UnderstaffedPRIDs)
+	case models.AuthoredPRActionClose:
+		return repo.ClosePRs(plan.PRIDs, s.clock.Now())
+	case models.AuthoredPRActionFlag:
+		return repo.MarkAuthorInactive(plan.PRIDs, s.clock.Now())
+	}
+
+	return nil
+}
+
+// applyAuthoredPlan отражает план обработки PR авторов на PR, ожидающих переназначения ревьюверов:
+// закрываемые PR исключаются, у передаваемых меняется автор, а новый автор снимается с ревью
+func applyAuthoredPlan(prs []models.PR, plan AuthoredPRPlan) []models.PR {
+	affected := make(map[string]bool, len(plan.PRIDs))
+	for _, prID := range plan.PRIDs {
+		affected[prID] = true
+	}
+
+	result := make([]models.PR, 0, len(prs))
+	for _, pr := range prs {
+		if affected[pr.ID] {
+			if plan.Action == models.AuthoredPRActionClose {
+				continue
+			}
+			if plan.Action == models.AuthoredPRActionTransfer {
+				pr.AuthorID = plan.NewAuthorID
+				reviewers := make([]models.User, 0, len(pr.Reviewers))
+				for _, reviewer := range pr.Reviewers {
+					if reviewer.ID != plan.NewAuthorID {
+						reviewers = append(reviewers, reviewer)
+					}
+				}
+				pr.Reviewers = reviewers
+			}
+		}
+		result = append(result, pr)
+	}
+
+	return result
+}
+
+// isAssigned проверяет, назначен ли пользователь ревьювером PR
+func isAssigned(pr models.PR, userID string) bool {
+	for _, reviewer := range pr.Reviewers {
+		if reviewer.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeIDs объединяет списки идентификаторов без повторов, сохраняя порядок
+func mergeIDs(lists ...[]string) []string {
+	merged := []string{}
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, id := range list {
+			if !seen[id] {
+				seen[id] = true
+				merged = append(merged, id)
+			}
+		}
+	}
+	return merged
+}
+
+// groupByTeam раскладывает PR по командам, которым они принадлежат, в порядке первого появления.
+// PR без команды относятся к основной команде автора, как при назначении ревьюверов, а если ее нет - к команде team
+func (s *Service) groupByTeam(repo RepositoryMethods, team *models.Team, prs []models.PR) ([]TeamPRs, error) {
+	groups := []TeamPRs{}
+	index := make(map[string]int)
+
 	for _, pr := range prs {
-		authorMap[pr.AuthorID] = true
+		teamID := pr.TeamID
+		if teamID == "" {
+			teamID = team.ID
+			author, err := repo.GetUserByID(pr.AuthorID)
+			if err != nil {
+				return nil, err
+			}
+			if primary, ok := author.PrimaryTeam(); ok {
+				teamID = primary.ID
+			}
+		}
+
+		if i, ok := index[teamID]; ok {
+			groups[i].PRs = append(groups[i].PRs, pr)
+			continue
+		}
+
+		prTeam := team
+		if teamID != team.ID {
+			var err error
+			prTeam, err = repo.GetTeamByID(teamID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		policy, err := repo.GetTeamPolicy(teamID)
+		if err != nil {
+			return nil, err
+		}
+
+		index[teamID] = len(groups)
+		groups = append(groups, TeamPRs{Team: prTeam, Policy: policy, PRs: []models.PR{pr}})
+	}
+
+	return groups, nil
+}
+
+// candidatePools собирает кандидатов на замену в порядке приоритета: своя команда,
+// запасные команды из политики, затем остальные команды, если политика это разрешает
+func (s *Service) candidatePools(repo RepositoryMethods, team *models.Team, policy *models.TeamPolicy, excludeUserIDs []string) ([]CandidatePool, error) {
+	teamCandidates, err := repo.GetActiveTeamMembersForReassignment(team.ID, excludeUserIDs, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	pools := []CandidatePool{{TeamName: team.Name, Users: teamCandidates}}
+
+	for _, fallback := range policy.FallbackTeams {
+		fallbackCandidates, err := repo.GetActiveTeamMembersForReassignment(fallback.FallbackTeamID, excludeUserIDs, s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, CandidatePool{TeamName: fallback.FallbackTeam.Name, Users: fallbackCandidates})
+	}
+
+	if policy.AllowCrossTeam {
+		outsiders, err := repo.GetActiveUsersOutsideTeam(team.ID, excludeUserIDs, s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, CandidatePool{Users: outsiders})
+	}
+
+	return pools, nil
+}
+
+// currentLoad возвращает текущее число открытых ревью у всех кандидатов из пулов
+func (s *Service) currentLoad(repo RepositoryMethods, pools []CandidatePool) (map[string]int, error) {
+	candidateIDs := []string{}
+	for _, pool := range pools {
+		for _, candidate := range pool.Users {
+			candidateIDs = append(candidateIDs, candidate.ID)
+		}
+	}
+
+	counts, err := repo.CountOpenReviews(candidateIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	load := make(map[string]int, len(candidateIDs))
+	for _, candidateID := range candidateIDs {
+		load[candidateID] = counts[candidateID]
+	}
+
+	return load, nil
+}
+
+// uncoveredPRs возвращает открытые PR, у которых после переназначений не останется ни одного ревьювера
+func (s *Service) uncoveredPRs(prs []models.PR, deactivatedUserIDs []string, reassignments []models.ReassignmentData) []string {
+	deactivatedMap := make(map[string]bool)
+	for _, userID := range deactivatedUserIDs {
+		deactivatedMap[userID] = true
 	}
 
-	authors := make([]string, 0, len(authorMap))
-	for authorID := range authorMap {
-		authors = append(authors, authorID)
+	replaced := make(map[string]bool)
+	for _, reassignment := range reassignments {
+		if reassignment.NewReviewerID != "" {
+			replaced[reassignment.PRID] = true
+		}
+	}
+
+	uncovered := []string{}
+	seen := make(map[string]bool)
+	for _, pr := range prs {
+		if pr.Status != models.PRStatusOpen || replaced[pr.ID] || seen[pr.ID] {
+			continue
+		}
+		seen[pr.ID] = true
+
+		covered := false
+		for _, reviewer := range pr.Reviewers {
+			if !deactivatedMap[reviewer.ID] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			uncovered = append(uncovered, pr.ID)
+		}
 	}
-	return authors
+
+	return uncovered
 }
 
-// prepareReassignments подготавливает данные для батчевого переназначения
-func (s *Service) prepareReassignments(prs []models.PR, deactivatedUserIDs []string, candidates []models.User) ([]models.ReassignmentData, []models.PRReassignmentInfo) {
+// prepareReassignments распределяет ревью деактивированных пользователей по кандидатам.
+// Каждое ревью получает наименее загруженный кандидат, который не является автором этого PR и еще не ревьюит его.
+// Пулы кандидатов просматриваются по порядку: следующий используется, только если в предыдущем никто не подходит.
+// Ревьюверы, которым не нашлось замены, снимаются с PR, а сами PR помечаются как недоукомплектованные.
+// Пока PR не хватает старших ревьюверов по политике, замена сначала ищется среди старших
+func (s *Service) prepareReassignments(prs []models.PR, deactivatedUserIDs []string, policy *models.TeamPolicy, pools []CandidatePool, load map[string]int) ReassignmentPlan {
 	deactivatedMap := make(map[string]bool)
 	for _, userID := range deactivatedUserIDs {
 		deactivatedMap[userID] = true
 	}
 
-	reassignments := []models.ReassignmentData{}
-	reassignmentInfos := []models.PRReassignmentInfo{}
-	candidateIndex := 0
+	plan := ReassignmentPlan{
+		Reassignments:     []models.ReassignmentData{},
+		Infos:             []models.PRReassignmentInfo{},
+		UnderstaffedPRIDs: []string{},
+		AtCapacityPRIDs:   []string{},
+		CandidateLoad:     load,
+
+		PolicyUnsatisfiedPRIDs: []string{},
+	}
 
 	for _, pr := range prs {
+		// Переназначаем только открытые PR: черновики, закрытые и смерженные не трогаем
+		if pr.Status != models.PRStatusOpen {
+			continue
+		}
+
+		assigned := make(map[string]bool, len(pr.Reviewers))
+		kept := make([]models.User, 0, len(pr.Reviewers))
+		for _, reviewer := range pr.Reviewers {
+			assigned[reviewer.ID] = true
+			if !deactivatedMap[reviewer.ID] {
+				kept = append(kept, reviewer)
+			}
+		}
+
+		understaffed, atCapacity := false, false
 		for _, reviewer := range pr.Reviewers {
-			// Если ревьювер деактивирован, переназначаем
-			if deactivatedMap[reviewer.ID] {
-				if candidateIndex < len(candidates) {
-					// Равномерно распределяем между кандидатами
-					newReviewer := candidates[candidateIndex%len(candidates)]
-					candidateIndex++
-
-					reassignments = append(reassignments, models.ReassignmentData{
-						PRID:          pr.ID,
-						OldReviewerID: reviewer.ID,
-						NewReviewerID: newReviewer.ID,
-					})
-
-					reassignmentInfos = append(reassignmentInfos, models.PRReassignmentInfo{
-						PRID:         pr.ID,
-						FromReviewer: reviewer.ID,
-						ToReviewer:   newReviewer.ID,
-					})
+			if !deactivatedMap[reviewer.ID] {
+				continue
+			}
+
+			eligible := func(candidate models.User) bool {
+				return candidate.ID != pr.AuthorID && !assigned[candidate.ID]
+			}
+			available := func(candidate models.User) bool {
+				return eligible(candidate) && hasCapacity(candidate, load)
+			}
+
+			var (
+				newReviewer     models.User
+				replacementTeam string
+				ok              bool
+			)
+			if policy.MissingSeniors(kept) > 0 {
+				newReviewer, replacementTeam, ok = leastLoaded(pools, load, func(candidate models.User) bool {
+					return available(candidate) && candidate.SeniorityAtLeast(policy.SeniorityLevel)
+				})
+			}
+			if !ok {
+				newReviewer, replacementTeam, ok = leastLoaded(pools, load, available)
+			}
+			if !ok {
+				// Кандидаты есть, но все заняты: не перегружаем их сверх лимита
+				if _, _, exists := leastLoaded(pools, load, eligible); exists {
+					atCapacity = true
 				}
+				plan.Reassignments = append(plan.Reassignments, models.ReassignmentData{
+					PRID:          pr.ID,
+					OldReviewerID: reviewer.ID,
+				})
+				understaffed = true
+				continue
+			}
+
+			assigned[newReviewer.ID] = true
+			kept = append(kept, newReviewer)
+			load[newReviewer.ID]++
+
+			plan.Reassignments = append(plan.Reassignments, models.ReassignmentData{
+				PRID:          pr.ID,
+				OldReviewerID: reviewer.ID,
+				NewReviewerID: newReviewer.ID,
+			})
+
+			plan.Infos = append(plan.Infos, models.PRReassignmentInfo{
+				PRID:            pr.ID,
+				FromReviewer:    reviewer.ID,
+				ToReviewer:      newReviewer.ID,
+				ReplacementTeam: replacementTeam,
+			})
+		}
+
+		if understaffed {
+			plan.UnderstaffedPRIDs = append(plan.UnderstaffedPRIDs, pr.ID)
+		}
+		if atCapacity {
+			plan.AtCapacityPRIDs = append(plan.AtCapacityPRIDs, pr.ID)
+		}
+		// Помечаем только PR, где правило нарушила эта деактивация
+		if policy.MissingSeniors(kept) > policy.MissingSeniors(pr.Reviewers) {
+			plan.PolicyUnsatisfiedPRIDs = append(plan.PolicyUnsatisfiedPRIDs, pr.ID)
+		}
+	}
+
+	return plan
+}
+
+// hasCapacity проверяет, что кандидат не достиг личного лимита открытых ревью с учетом уже распределенных
+func hasCapacity(candidate models.User, load map[string]int) bool {
+	return candidate.MaxOpenReviews <= 0 || load[candidate.ID] < candidate.MaxOpenReviews
+}
+
+// leastLoaded возвращает подходящего кандидата с наименьшей нагрузкой из первого пула, где такой есть,
+// и команду, из которой он взят. При равной нагрузке выбирается кандидат, идущий раньше в пуле
+func leastLoaded(pools []CandidatePool, load map[string]int, eligible func(models.User) bool) (models.User, string, bool) {
+	for _, pool := range pools {
+		var (
+			best  models.User
+			found bool
+		)
+		for _, candidate := range pool.Users {
+			if !eligible(candidate) {
+				continue
+			}
+			if !found || load[candidate.ID] < load[best.ID] {
+				best = candidate
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		teamName := pool.TeamName
+		if teamName == "" {
+			if team, ok := best.PrimaryTeam(); ok {
+				teamName = team.Name
 			}
 		}
+		return best, teamName, true
 	}
 
-	return reassignments, reassignmentInfos
+	return models.User{}, "", false
 }
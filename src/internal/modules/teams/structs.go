@@ -1,5 +1,7 @@
 package teams
 
+import "github.com/tomatoCoderq/avito_task/src/models"
+
 // DeactivationRequestData - внутренняя структура для запроса деактивации
 type DeactivationRequestData struct {
 	TeamName string   `json:"team_name"`
@@ -18,4 +20,41 @@ type BatchUpdateResult struct {
 	Updated int
 	Failed  int
 	Errors  []string
-}
\ No newline at end of file
+}
+
+// ReassignmentPlan - план переназначения ревьюверов при деактивации (внутренняя структура)
+type ReassignmentPlan struct {
+	Reassignments     []models.ReassignmentData
+	Infos             []models.PRReassignmentInfo
+	UnderstaffedPRIDs []string
+	// AtCapacityPRIDs - PR, где замена не нашлась, потому что все подходящие кандидаты достигли лимита ревью
+	AtCapacityPRIDs []string
+	// PolicyUnsatisfiedPRIDs - PR, где не нашлось замены нужного политике уровня старшинства
+	PolicyUnsatisfiedPRIDs []string
+	CandidateLoad          map[string]int
+}
+
+// AuthoredPRPlan - план обработки незавершенных PR деактивируемых авторов (внутренняя структура)
+type AuthoredPRPlan struct {
+	Action      string
+	NewAuthorID string
+	PRIDs       []string
+	Infos       []models.AuthoredPRInfo
+	// UnderstaffedPRIDs - PR, в которых новый автор был ревьювером и снимается с ревью
+	UnderstaffedPRIDs []string
+}
+
+// CandidatePool - кандидаты на замену ревьювера из одной команды (внутренняя структура).
+// Пустой TeamName означает кандидатов из любых других команд
+type CandidatePool struct {
+	TeamName string
+	Users    []models.User
+}
+
+// TeamPRs - открытые PR одной команды вместе с ее политикой (внутренняя структура).
+// Замены ревьюверов в PR подбираются по команде PR, а не по команде деактивируемого пользователя
+type TeamPRs struct {
+	Team   *models.Team
+	Policy *models.TeamPolicy
+	PRs    []models.PR
+}
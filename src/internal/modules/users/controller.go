@@ -2,6 +2,9 @@ package users
 
 import (
 	"errors"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tomatoCoderq/avito_task/src/models"
@@ -9,8 +12,18 @@ import (
 )
 
 type ServiceMethods interface {
-	SetIsActive(userID string, isActive bool) (*models.User, error)
+	SetIsActive(userID string, isActive bool, opts ActivityOptions) (*models.User, *models.DeactivationResult, error)
 	GetUserReviews(userID string) ([]models.PR, error)
+	SetMaxOpenReviews(userID string, maxOpenReviews int) (*models.User, error)
+	SetPrimaryTeam(userID string, teamName string) (*models.User, error)
+	SetTags(userID string, tags []string) (*models.User, error)
+	SetSeniority(userID string, seniority string) (*models.User, error)
+	Snooze(userID string, until *time.Time) (*models.User, error)
+	CreateAbsence(userID string, startsAt, endsAt time.Time, reason string) (*models.Absence, error)
+	ListAbsences(userID string) ([]models.Absence, error)
+	UpdateAbsence(id uint, startsAt, endsAt time.Time, reason string) (*models.Absence, error)
+	DeleteAbsence(id uint) error
+	ImportAbsences(r io.Reader, defaultUserID string) (*AbsenceImportResult, error)
 }
 
 type Controller struct {
@@ -25,9 +38,148 @@ func RegisterController(service ServiceMethods) *Controller {
 
 // SetIsActive устанавливает флаг активности пользователя
 func (c *Controller) SetIsActive(ctx *gin.Context) {
+	var req struct {
+		UserID           string `json:"user_id" binding:"required"`
+		IsActive         bool   `json:"is_active"`
+		AuthoredPRAction string `json:"authored_prs_action"`
+		TransferTo       string `json:"transfer_to"`
+		RejoinRotation   *bool  `json:"rejoin_rotation"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.AuthoredPRAction != "" && !models.IsValidAuthoredPRAction(req.AuthoredPRAction)) {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	// По умолчанию реактивированный пользователь сразу возвращается в ротацию
+	rejoinRotation := req.RejoinRotation == nil || *req.RejoinRotation
+
+	user, deactivation, err := c.service.SetIsActive(req.UserID, req.IsActive, ActivityOptions{
+		Deactivation: models.DeactivationOptions{
+			AuthoredPRAction: req.AuthoredPRAction,
+			TransferTo:       req.TransferTo,
+		},
+		RejoinRotation: rejoinRotation,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "user not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		for _, code := range []string{"NO_TEAM_LEAD", "INVALID_TRANSFER_TARGET", "POLICY_UNSATISFIED"} {
+			if strings.HasPrefix(err.Error(), code) {
+				ctx.JSON(409, gin.H{
+					"error": gin.H{
+						"code":    code,
+						"message": strings.TrimPrefix(err.Error(), code+": "),
+					},
+				})
+				return
+			}
+		}
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update user",
+			},
+		})
+		return
+	}
+
+	teamName := ""
+	if team, ok := user.PrimaryTeam(); ok {
+		teamName = team.Name
+	}
+
+	response := gin.H{
+		"user": gin.H{
+			"user_id":     user.ID,
+			"username":    user.Name,
+			"team_name":   teamName,
+			"is_active":   user.IsActive,
+			"in_rotation": user.IsActive && !user.OutOfRotation,
+		},
+	}
+	if deactivation != nil {
+		response["deactivation"] = deactivation
+	}
+
+	ctx.JSON(200, response)
+}
+
+// SetMaxOpenReviews задает лимит открытых ревью пользователя
+func (c *Controller) SetMaxOpenReviews(ctx *gin.Context) {
+	var req struct {
+		UserID         string `json:"user_id" binding:"required"`
+		MaxOpenReviews *int   `json:"max_open_reviews" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	user, err := c.service.SetMaxOpenReviews(req.UserID, *req.MaxOpenReviews)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "user not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "INVALID_CAPACITY") {
+			ctx.JSON(400, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_REQUEST",
+					"message": "max_open_reviews must not be negative",
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update user",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"user": gin.H{
+			"user_id":          user.ID,
+			"username":         user.Name,
+			"is_active":        user.IsActive,
+			"max_open_reviews": user.MaxOpenReviews,
+		},
+	})
+}
+
+// SetPrimaryTeam задает основную команду пользователя для подбора ревьюверов его PR
+func (c *Controller) SetPrimaryTeam(ctx *gin.Context) {
 	var req struct {
 		UserID   string `json:"user_id" binding:"required"`
-		IsActive bool   `json:"is_active"`
+		TeamName string `json:"team_name"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -40,7 +192,7 @@ func (c *Controller) SetIsActive(ctx *gin.Context) {
 		return
 	}
 
-	user, err := c.service.SetIsActive(req.UserID, req.IsActive)
+	user, err := c.service.SetPrimaryTeam(req.UserID, req.TeamName)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		ctx.JSON(404, gin.H{
 			"error": gin.H{
@@ -51,6 +203,15 @@ func (c *Controller) SetIsActive(ctx *gin.Context) {
 		return
 	}
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "NOT_TEAM_MEMBER") {
+			ctx.JSON(409, gin.H{
+				"error": gin.H{
+					"code":    "NOT_TEAM_MEMBER",
+					"message": "user is not a member of the team",
+				},
+			})
+			return
+		}
 		ctx.JSON(500, gin.H{
 			"error": gin.H{
 				"code":    "INTERNAL_ERROR",
@@ -61,16 +222,176 @@ func (c *Controller) SetIsActive(ctx *gin.Context) {
 	}
 
 	teamName := ""
-	if len(user.Teams) > 0 {
-		teamName = user.Teams[0].Name
+	if team, ok := user.PrimaryTeam(); ok {
+		teamName = team.Name
 	}
 
 	ctx.JSON(200, gin.H{
 		"user": gin.H{
 			"user_id":   user.ID,
 			"username":  user.Name,
+			"is_active": user.IsActive,
 			"team_name": teamName,
+		},
+	})
+}
+
+// SetTags заменяет навыки пользователя для подбора ревьюверов по тегам
+func (c *Controller) SetTags(ctx *gin.Context) {
+	var req struct {
+		UserID string   `json:"user_id" binding:"required"`
+		Tags   []string `json:"tags"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	user, err := c.service.SetTags(req.UserID, req.Tags)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "user not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update user",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"user": gin.H{
+			"user_id":   user.ID,
+			"username":  user.Name,
+			"is_active": user.IsActive,
+			"tags":      user.Tags,
+		},
+	})
+}
+
+// SetSeniority задает уровень старшинства пользователя: junior, middle, senior или lead
+func (c *Controller) SetSeniority(ctx *gin.Context) {
+	var req struct {
+		UserID    string `json:"user_id" binding:"required"`
+		Seniority string `json:"seniority"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	user, err := c.service.SetSeniority(req.UserID, req.Seniority)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "user not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "INVALID_SENIORITY") {
+			ctx.JSON(400, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_REQUEST",
+					"message": "seniority must be junior, middle, senior or lead",
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update user",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"user": gin.H{
+			"user_id":   user.ID,
+			"username":  user.Name,
 			"is_active": user.IsActive,
+			"seniority": user.Seniority,
+		},
+	})
+}
+
+// Snooze временно исключает пользователя из автоназначения ревьюверов до момента until.
+// Текущие ревью и флаг is_active не меняются, запрос без until снимает отсрочку
+func (c *Controller) Snooze(ctx *gin.Context) {
+	var req struct {
+		UserID string     `json:"user_id" binding:"required"`
+		Until  *time.Time `json:"until"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	user, err := c.service.Snooze(req.UserID, req.Until)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "user not found",
+			},
+		})
+		return
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "INVALID_SNOOZE") {
+			ctx.JSON(400, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_REQUEST",
+					"message": "until must be in the future",
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update user",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"user": gin.H{
+			"user_id":       user.ID,
+			"username":      user.Name,
+			"is_active":     user.IsActive,
+			"snoozed_until": user.SnoozedUntil,
 		},
 	})
 }
@@ -114,3 +435,211 @@ func (c *Controller) GetReview(ctx *gin.Context) {
 		"pull_requests": pullRequests,
 	})
 }
+
+// AbsenceCreate регистрирует период отсутствия пользователя
+func (c *Controller) AbsenceCreate(ctx *gin.Context) {
+	var req struct {
+		UserID   string    `json:"user_id" binding:"required"`
+		StartsAt time.Time `json:"starts_at" binding:"required"`
+		EndsAt   time.Time `json:"ends_at" binding:"required"`
+		Reason   string    `json:"reason"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	absence, err := c.service.CreateAbsence(req.UserID, req.StartsAt, req.EndsAt, req.Reason)
+	if err != nil {
+		absenceError(ctx, err, "Failed to create absence")
+		return
+	}
+
+	ctx.JSON(201, gin.H{"absence": absenceResponse(absence)})
+}
+
+// AbsenceList возвращает периоды отсутствия пользователя
+func (c *Controller) AbsenceList(ctx *gin.Context) {
+	userID := ctx.Query("user_id")
+	if userID == "" {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "user_id query parameter is required",
+			},
+		})
+		return
+	}
+
+	absences, err := c.service.ListAbsences(userID)
+	if err != nil {
+		absenceError(ctx, err, "Failed to get absences")
+		return
+	}
+
+	items := make([]gin.H, 0, len(absences))
+	for i := range absences {
+		items = append(items, absenceResponse(&absences[i]))
+	}
+
+	ctx.JSON(200, gin.H{
+		"user_id":  userID,
+		"absences": items,
+	})
+}
+
+// AbsenceUpdate меняет границы и причину периода отсутствия
+func (c *Controller) AbsenceUpdate(ctx *gin.Context) {
+	var req struct {
+		AbsenceID uint      `json:"absence_id" binding:"required"`
+		StartsAt  time.Time `json:"starts_at" binding:"required"`
+		EndsAt    time.Time `json:"ends_at" binding:"required"`
+		Reason    string    `json:"reason"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	absence, err := c.service.UpdateAbsence(req.AbsenceID, req.StartsAt, req.EndsAt, req.Reason)
+	if err != nil {
+		absenceError(ctx, err, "Failed to update absence")
+		return
+	}
+
+	ctx.JSON(200, gin.H{"absence": absenceResponse(absence)})
+}
+
+// AbsenceDelete удаляет период отсутствия
+func (c *Controller) AbsenceDelete(ctx *gin.Context) {
+	var req struct {
+		AbsenceID uint `json:"absence_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "Invalid request body",
+			},
+		})
+		return
+	}
+
+	if err := c.service.DeleteAbsence(req.AbsenceID); err != nil {
+		absenceError(ctx, err, "Failed to delete absence")
+		return
+	}
+
+	ctx.JSON(200, gin.H{"absence_id": req.AbsenceID})
+}
+
+// AbsenceImport загружает периоды отсутствия из файла iCalendar (.ics), переданного в поле file.
+// Поле user_id задает пользователя для событий без свойства X-USER-ID
+func (c *Controller) AbsenceImport(ctx *gin.Context) {
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "file is required",
+			},
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_REQUEST",
+				"message": "cannot read file",
+			},
+		})
+		return
+	}
+	defer file.Close()
+
+	result, err := c.service.ImportAbsences(file, ctx.PostForm("user_id"))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "INVALID_CALENDAR") {
+			ctx.JSON(400, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_CALENDAR",
+					"message": strings.TrimPrefix(err.Error(), "INVALID_CALENDAR: "),
+				},
+			})
+			return
+		}
+		ctx.JSON(500, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to import absences",
+			},
+		})
+		return
+	}
+
+	imported := make([]gin.H, 0, len(result.Imported))
+	for i := range result.Imported {
+		imported = append(imported, absenceResponse(&result.Imported[i]))
+	}
+
+	ctx.JSON(200, gin.H{
+		"imported": imported,
+		"errors":   result.Errors,
+	})
+}
+
+// absenceError формирует ответ с ошибкой операции над периодом отсутствия
+func absenceError(ctx *gin.Context, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(404, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "user or absence not found",
+			},
+		})
+		return
+	}
+	if strings.HasPrefix(err.Error(), "INVALID_ABSENCE") {
+		ctx.JSON(400, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ABSENCE",
+				"message": strings.TrimPrefix(err.Error(), "INVALID_ABSENCE: "),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(500, gin.H{
+		"error": gin.H{
+			"code":    "INTERNAL_ERROR",
+			"message": message,
+		},
+	})
+}
+
+// absenceResponse формирует тело ответа с периодом отсутствия
+func absenceResponse(absence *models.Absence) gin.H {
+	return gin.H{
+		"absence_id": absence.ID,
+		"user_id":    absence.UserID,
+		"starts_at":  absence.StartsAt,
+		"ends_at":    absence.EndsAt,
+		"reason":     absence.Reason,
+		"status":     absence.Status,
+	}
+}
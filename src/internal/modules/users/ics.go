@@ -0,0 +1,192 @@
+package users
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// parseCalendar разбирает события VEVENT из файла iCalendar (.ics).
+// Пользователь события берется из свойства X-USER-ID. События, которые не удалось разобрать,
+// попадают в список ошибок с номером события и не прерывают разбор остальных
+func parseCalendar(r io.Reader) ([]CalendarEvent, []string, error) {
+	lines, err := unfoldCalendarLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := []CalendarEvent{}
+	errs := []string{}
+
+	var (
+		props   map[string]calendarProperty
+		inEvent bool
+		number  int
+	)
+	for _, line := range lines {
+		switch strings.ToUpper(line) {
+		case "BEGIN:VEVENT":
+			inEvent = true
+			number++
+			props = make(map[string]calendarProperty)
+			continue
+		case "END:VEVENT":
+			if !inEvent {
+				continue
+			}
+			inEvent = false
+
+			event, err := calendarEvent(props)
+			event.Number = number
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("event %d: %s", number, err))
+				continue
+			}
+			events = append(events, event)
+			continue
+		}
+
+		if !inEvent {
+			continue
+		}
+
+		prop, ok := parseCalendarProperty(line)
+		if ok {
+			props[prop.name] = prop
+		}
+	}
+
+	if number == 0 {
+		return nil, nil, errors.New("INVALID_CALENDAR: no events found")
+	}
+
+	return events, errs, nil
+}
+
+// calendarProperty - свойство события iCalendar с параметрами
+type calendarProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfoldCalendarLines читает строки календаря, склеивая перенесенные строки (RFC 5545, 3.1)
+func unfoldCalendarLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseCalendarProperty разбирает строку вида NAME;PARAM=VALUE:value
+func parseCalendarProperty(line string) (calendarProperty, bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return calendarProperty{}, false
+	}
+
+	parts := strings.Split(head, ";")
+	prop := calendarProperty{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string, len(parts)-1),
+		value:  value,
+	}
+	for _, param := range parts[1:] {
+		key, paramValue, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(paramValue, `"`)
+	}
+
+	return prop, true
+}
+
+// calendarEvent собирает отсутствие из свойств события
+func calendarEvent(props map[string]calendarProperty) (CalendarEvent, error) {
+	start, ok := props["DTSTART"]
+	if !ok {
+		return CalendarEvent{}, errors.New("missing DTSTART")
+	}
+
+	startsAt, allDay, err := parseCalendarTime(start)
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+
+	var endsAt time.Time
+	if end, ok := props["DTEND"]; ok {
+		endsAt, _, err = parseCalendarTime(end)
+		if err != nil {
+			return CalendarEvent{}, err
+		}
+	} else if allDay {
+		// Событие на весь день без DTEND длится один день
+		endsAt = startsAt.AddDate(0, 0, 1)
+	} else {
+		return CalendarEvent{}, errors.New("missing DTEND")
+	}
+
+	return CalendarEvent{
+		UserID:   strings.TrimSpace(props["X-USER-ID"].value),
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		Summary:  unescapeCalendarText(props["SUMMARY"].value),
+	}, nil
+}
+
+// parseCalendarTime разбирает DATE и DATE-TIME в UTC, с TZID или без зоны.
+// Второе значение сообщает, что задана только дата
+func parseCalendarTime(prop calendarProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.value)
+
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s: %s", prop.name, value)
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s: %s", prop.name, value)
+		}
+		return t, false, nil
+	}
+
+	loc := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		tz, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %s", tzid)
+		}
+		loc = tz
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s: %s", prop.name, value)
+	}
+	return t, false, nil
+}
+
+// unescapeCalendarText снимает экранирование текстовых значений iCalendar
+func unescapeCalendarText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
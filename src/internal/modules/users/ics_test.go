@@ -0,0 +1,264 @@
+package users
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// calendar собирает файл iCalendar из строк с переводами строк CRLF, как в RFC 5545
+func calendar(lines ...string) string {
+	all := append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...)
+	all = append(all, "END:VCALENDAR")
+	return strings.Join(all, "\r\n") + "\r\n"
+}
+
+func TestParseCalendar(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  CalendarEvent
+	}{
+		{
+			name: "UTC date-time",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"X-USER-ID:u1",
+				"DTSTART:20251201T090000Z",
+				"DTEND:20251205T180000Z",
+				"SUMMARY:Vacation",
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				UserID:   "u1",
+				StartsAt: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2025, 12, 5, 18, 0, 0, 0, time.UTC),
+				Summary:  "Vacation",
+			},
+		},
+		{
+			name: "TZID date-time",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"DTSTART;TZID=Europe/Moscow:20251201T090000",
+				`DTEND;TZID="Europe/Moscow":20251201T180000`,
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				StartsAt: time.Date(2025, 12, 1, 9, 0, 0, 0, moscow),
+				EndsAt:   time.Date(2025, 12, 1, 18, 0, 0, 0, moscow),
+			},
+		},
+		{
+			name: "floating date-time is UTC",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"DTSTART:20251201T090000",
+				"DTEND:20251201T100000",
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				StartsAt: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "VALUE=DATE with DTEND",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"DTSTART;VALUE=DATE:20251201",
+				"DTEND;VALUE=DATE:20251204",
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				StartsAt: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "all-day event without DTEND lasts one day",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"DTSTART;VALUE=DATE:20251231",
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				StartsAt: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "date without VALUE parameter",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"DTSTART:20251201",
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				StartsAt: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "folded lines and escaped text",
+			input: calendar(
+				"BEGIN:VEVENT",
+				"X-USER-ID:u",
+				" 42",
+				"DTSTART:20251201T09",
+				"\t0000Z",
+				"DTEND:20251201T100000Z",
+				`SUMMARY:Conference\, day one\n`,
+				" and travel",
+				"END:VEVENT",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				UserID:   "u42",
+				StartsAt: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC),
+				Summary:  "Conference, day one\nand travel",
+			},
+		},
+		{
+			name: "lowercase markers and property names",
+			input: calendar(
+				"begin:vevent",
+				"x-user-id:u1",
+				"dtstart;value=DATE:20251201",
+				"end:vevent",
+			),
+			want: CalendarEvent{
+				Number:   1,
+				UserID:   "u1",
+				StartsAt: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, errs, err := parseCalendar(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseCalendar: %v", err)
+			}
+			if len(errs) != 0 {
+				t.Fatalf("unexpected event errors: %v", errs)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+
+			got := events[0]
+			if !got.StartsAt.Equal(tt.want.StartsAt) || !got.EndsAt.Equal(tt.want.EndsAt) {
+				t.Errorf("got %v - %v, want %v - %v", got.StartsAt, got.EndsAt, tt.want.StartsAt, tt.want.EndsAt)
+			}
+			got.StartsAt, got.EndsAt = tt.want.StartsAt, tt.want.EndsAt
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCalendarMalformedEvents(t *testing.T) {
+	input := calendar(
+		"BEGIN:VEVENT",
+		"DTEND:20251201T100000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20251201T090000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:2025-12-01",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;TZID=Mars/Olympus:20251201T090000",
+		"DTEND;TZID=Mars/Olympus:20251201T100000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20251201",
+		"DTEND:not-a-date",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"X-USER-ID:u1",
+		"DTSTART;VALUE=DATE:20251210",
+		"END:VEVENT",
+	)
+
+	events, errs, err := parseCalendar(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseCalendar: %v", err)
+	}
+
+	wantErrs := []string{
+		"event 1: missing DTSTART",
+		"event 2: missing DTEND",
+		"event 3: invalid DTSTART: 2025-12-01",
+		"event 4: unknown TZID Mars/Olympus",
+		"event 5: invalid DTEND: not-a-date",
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Errorf("got errors %q, want %q", errs, wantErrs)
+	}
+
+	// Ошибки в одних событиях не мешают разбору остальных, номер события сохраняется
+	if len(events) != 1 || events[0].Number != 6 || events[0].UserID != "u1" {
+		t.Errorf("got events %+v, want only event 6 of user u1", events)
+	}
+}
+
+func TestParseCalendarWithoutEvents(t *testing.T) {
+	_, _, err := parseCalendar(strings.NewReader(calendar("PRODID:-//test//EN")))
+	if err == nil || !strings.HasPrefix(err.Error(), "INVALID_CALENDAR") {
+		t.Fatalf("got error %v, want INVALID_CALENDAR", err)
+	}
+}
+
+func TestImportAbsencesReportsEventLabels(t *testing.T) {
+	input := calendar(
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20251201",
+		"SUMMARY:Sick leave",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20251202",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Broken",
+		"END:VEVENT",
+	)
+
+	// Без пользователя события не доходят до репозитория
+	service := RegisterService(nil, nil, nil, nil)
+	result, err := service.ImportAbsences(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatalf("ImportAbsences: %v", err)
+	}
+
+	wantErrs := []string{
+		"event 3: missing DTSTART",
+		"event 1 (Sick leave): user is not specified",
+		"event 2: user is not specified",
+	}
+	if !reflect.DeepEqual(result.Errors, wantErrs) {
+		t.Errorf("got errors %q, want %q", result.Errors, wantErrs)
+	}
+	if len(result.Imported) != 0 {
+		t.Errorf("got %d imported absences, want 0", len(result.Imported))
+	}
+}
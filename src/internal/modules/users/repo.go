@@ -1,6 +1,8 @@
 package users
 
 import (
+	"time"
+
 	"github.com/tomatoCoderq/avito_task/src/models"
 	"gorm.io/gorm"
 )
@@ -15,17 +17,108 @@ func NewRepo(db *gorm.DB) *Repo {
 	}
 }
 
-func (r *Repo) SetIsActive(userID string, isActive bool) (*models.User, error) {
+// Reactivate активирует пользователя. Если inRotation не задан, пользователь не участвует в автоназначении ревьюверов
+func (r *Repo) Reactivate(userID string, inRotation bool) (*models.User, error) {
 	var user models.User
 	if err := r.db.First(&user, "id = ?", userID).Error; err != nil {
 		return nil, err
 	}
 
-	user.IsActive = isActive
+	user.IsActive = true
+	user.OutOfRotation = !inRotation
 	if err := r.db.Save(&user).Error; err != nil {
 		return nil, err
 	}
 
+	// У вернувшегося автора снимаем пометку с оставленных PR
+	if err := r.db.Model(&models.PR{}).
+		Where("author_id = ? AND author_inactive = ?", userID, true).
+		Update("author_inactive", false).Error; err != nil {
+		return nil, err
+	}
+
+	return r.GetUserByID(userID)
+}
+
+// SetMaxOpenReviews задает лимит открытых ревью пользователя
+func (r *Repo) SetMaxOpenReviews(userID string, maxOpenReviews int) (*models.User, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("max_open_reviews", maxOpenReviews)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetUserByID(userID)
+}
+
+// SetPrimaryTeam задает основную команду пользователя
+func (r *Repo) SetPrimaryTeam(userID string, teamID string) (*models.User, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("primary_team_id", teamID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetUserByID(userID)
+}
+
+// SetTags заменяет навыки пользователя
+func (r *Repo) SetTags(userID string, tags []string) (*models.User, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Select("tags").
+		Updates(&models.User{Tags: tags})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetUserByID(userID)
+}
+
+// SetSeniority задает уровень старшинства пользователя
+func (r *Repo) SetSeniority(userID string, seniority string) (*models.User, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("seniority", seniority)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetUserByID(userID)
+}
+
+// SetSnoozedUntil задает момент окончания отсрочки назначения пользователя. nil снимает отсрочку
+func (r *Repo) SetSnoozedUntil(userID string, until *time.Time) (*models.User, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("snoozed_until", until)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return r.GetUserByID(userID)
+}
+
+// GetUserByID получает пользователя вместе с его командами
+func (r *Repo) GetUserByID(userID string) (*models.User, error) {
+	var user models.User
 	if err := r.db.Preload("Teams").First(&user, "id = ?", userID).Error; err != nil {
 		return nil, err
 	}
@@ -33,13 +126,14 @@ func (r *Repo) SetIsActive(userID string, isActive bool) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUserReviews получает PR, где пользователь назначен ревьювером. Закрытые без слияния PR не возвращаются
 func (r *Repo) GetUserReviews(userID string) ([]models.PR, error) {
 	var prs []models.PR
 
-	
 	if err := r.db.
 		Joins("JOIN pr_reviewers ON pr_reviewers.pr_id = prs.id").
 		Where("pr_reviewers.user_id = ?", userID).
+		Where("prs.status != ?", models.PRStatusClosed).
 		Preload("Author").
 		Find(&prs).Error; err != nil {
 		return nil, err
@@ -48,3 +142,71 @@ func (r *Repo) GetUserReviews(userID string) ([]models.PR, error) {
 	return prs, nil
 }
 
+// CreateAbsence сохраняет период отсутствия
+func (r *Repo) CreateAbsence(absence *models.Absence) (*models.Absence, error) {
+	if err := r.db.Omit("User").Create(absence).Error; err != nil {
+		return nil, err
+	}
+	return absence, nil
+}
+
+// GetAbsence получает период отсутствия по ID
+func (r *Repo) GetAbsence(id uint) (*models.Absence, error) {
+	var absence models.Absence
+	if err := r.db.First(&absence, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &absence, nil
+}
+
+// ListAbsences получает периоды отсутствия пользователя в порядке начала
+func (r *Repo) ListAbsences(userID string) ([]models.Absence, error) {
+	var absences []models.Absence
+	if err := r.db.
+		Where("user_id = ?", userID).
+		Order("starts_at, id").
+		Find(&absences).Error; err != nil {
+		return nil, err
+	}
+	return absences, nil
+}
+
+// UpdateAbsence сохраняет измененный период отсутствия
+func (r *Repo) UpdateAbsence(absence *models.Absence) (*models.Absence, error) {
+	if err := r.db.Omit("User").Save(absence).Error; err != nil {
+		return nil, err
+	}
+	return absence, nil
+}
+
+// DeleteAbsence удаляет период отсутствия
+func (r *Repo) DeleteAbsence(id uint) error {
+	return r.db.Delete(&models.Absence{}, "id = ?", id).Error
+}
+
+// GetAbsencesToStart получает запланированные отсутствия, период которых уже идет
+func (r *Repo) GetAbsencesToStart(now time.Time) ([]models.Absence, error) {
+	var absences []models.Absence
+	if err := r.db.
+		Where("status = ? AND starts_at <= ? AND ends_at > ?", models.AbsenceStatusScheduled, now, now).
+		Order("starts_at, id").
+		Find(&absences).Error; err != nil {
+		return nil, err
+	}
+	return absences, nil
+}
+
+// SetAbsenceStatus меняет статус периода отсутствия
+func (r *Repo) SetAbsenceStatus(id uint, status string) error {
+	return r.db.Model(&models.Absence{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+}
+
+// FinishAbsences завершает отсутствия, период которых закончился, и возвращает их число
+func (r *Repo) FinishAbsences(now time.Time) (int64, error) {
+	result := r.db.Model(&models.Absence{}).
+		Where("status <> ? AND ends_at <= ?", models.AbsenceStatusFinished, now).
+		Update("status", models.AbsenceStatusFinished)
+	return result.RowsAffected, result.Error
+}
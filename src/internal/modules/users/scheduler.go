@@ -0,0 +1,69 @@
+package users
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// AbsenceScheduler в фоне отслеживает периоды отсутствия: при начале периода переназначает ревью
+// пользователя, а при окончании запускает доназначение ревьюверов
+type AbsenceScheduler struct {
+	service  *Service
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	start    sync.Once
+	started  bool
+}
+
+func NewAbsenceScheduler(service *Service, interval time.Duration) *AbsenceScheduler {
+	return &AbsenceScheduler{
+		service:  service,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start запускает фоновый цикл. Повторные вызовы игнорируются
+func (s *AbsenceScheduler) Start() {
+	s.start.Do(func() {
+		s.started = true
+		go s.run()
+	})
+}
+
+// Stop останавливает фоновый цикл и дожидается завершения текущего прохода
+func (s *AbsenceScheduler) Stop() {
+	s.start.Do(func() {})
+	if !s.started {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+}
+
+func (s *AbsenceScheduler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		started, finished, err := s.service.ProcessAbsences()
+		if err != nil {
+			slog.Error("absence processing failed", "error", err)
+		}
+		if started > 0 || finished > 0 {
+			slog.Info("absences processed", "started", started, "finished", finished)
+		}
+	}
+}
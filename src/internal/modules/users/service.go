@@ -1,26 +1,364 @@
 package users
 
-import "github.com/tomatoCoderq/avito_task/src/models"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/tomatoCoderq/avito_task/src/models"
+)
 
 type RepositoryMethods interface {
-	SetIsActive(userID string, isActive bool) (*models.User, error)
+	Reactivate(userID string, inRotation bool) (*models.User, error)
+	GetUserByID(userID string) (*models.User, error)
+	SetMaxOpenReviews(userID string, maxOpenReviews int) (*models.User, error)
+	SetPrimaryTeam(userID string, teamID string) (*models.User, error)
+	SetTags(userID string, tags []string) (*models.User, error)
+	SetSeniority(userID string, seniority string) (*models.User, error)
+	SetSnoozedUntil(userID string, until *time.Time) (*models.User, error)
+	CreateAbsence(absence *models.Absence) (*models.Absence, error)
+	GetAbsence(id uint) (*models.Absence, error)
+	ListAbsences(userID string) ([]models.Absence, error)
+	UpdateAbsence(absence *models.Absence) (*models.Absence, error)
+	DeleteAbsence(id uint) error
+	GetAbsencesToStart(now time.Time) ([]models.Absence, error)
+	SetAbsenceStatus(id uint, status string) error
+	FinishAbsences(now time.Time) (int64, error)
 	GetUserReviews(userID string) ([]models.PR, error)
 }
 
+// ReviewerSweeper запускает доназначение ревьюверов в PR, которым их не хватает
+type ReviewerSweeper interface {
+	Trigger()
+}
+
+// Deactivator деактивирует пользователя с переназначением его ревью
+type Deactivator interface {
+	DeactivateUser(userID string, opts models.DeactivationOptions) (*models.DeactivationResult, error)
+}
+
+// Clock возвращает текущее время
+type Clock interface {
+	Now() time.Time
+}
+
 type Service struct {
-	repo RepositoryMethods
+	repo        RepositoryMethods
+	sweeper     ReviewerSweeper
+	deactivator Deactivator
+	clock       Clock
 }
 
-func RegisterService(repo RepositoryMethods) *Service {
+// RegisterService создает сервис пользователей. clock задает время, по которому начинаются и заканчиваются отсутствия
+func RegisterService(repo RepositoryMethods, sweeper ReviewerSweeper, deactivator Deactivator, clock Clock) *Service {
 	return &Service{
-		repo: repo,
+		repo:        repo,
+		sweeper:     sweeper,
+		deactivator: deactivator,
+		clock:       clock,
+	}
+}
+
+// SetIsActive меняет флаг активности пользователя. Деактивация переназначает ревью пользователя
+// так же, как массовая деактивация команды, и возвращает ее результат.
+// При реактивации результат деактивации равен nil
+func (s *Service) SetIsActive(userID string, isActive bool, opts ActivityOptions) (*models.User, *models.DeactivationResult, error) {
+	if !isActive {
+		result, err := s.deactivator.DeactivateUser(userID, opts.Deactivation)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		user, err := s.repo.GetUserByID(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return user, result, nil
+	}
+
+	user, err := s.repo.Reactivate(userID, opts.RejoinRotation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Вернувшийся в ротацию пользователь может закрыть нехватку ревьюверов в открытых PR
+	if opts.RejoinRotation {
+		s.sweeper.Trigger()
+	}
+
+	return user, nil, nil
+}
+
+// SetMaxOpenReviews задает лимит открытых ревью пользователя. 0 снимает лимит
+func (s *Service) SetMaxOpenReviews(userID string, maxOpenReviews int) (*models.User, error) {
+	if maxOpenReviews < 0 {
+		return nil, errors.New("INVALID_CAPACITY: max_open_reviews must not be negative")
+	}
+
+	user, err := s.repo.SetMaxOpenReviews(userID, maxOpenReviews)
+	if err != nil {
+		return nil, err
+	}
+
+	// Увеличенный лимит может закрыть нехватку ревьюверов в открытых PR
+	s.sweeper.Trigger()
+
+	return user, nil
+}
+
+// SetPrimaryTeam задает основную команду пользователя, по которой подбираются ревьюверы его PR,
+// если команда не указана при создании. Пустое имя сбрасывает настройку
+func (s *Service) SetPrimaryTeam(userID string, teamName string) (*models.User, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	teamID := ""
+	if teamName != "" {
+		team, ok := user.TeamByName(teamName)
+		if !ok {
+			return nil, errors.New("NOT_TEAM_MEMBER: user is not a member of team " + teamName)
+		}
+		teamID = team.ID
+	}
+
+	return s.repo.SetPrimaryTeam(userID, teamID)
+}
+
+// SetTags заменяет навыки пользователя, по которым подбираются ревьюверы PR с требуемыми тегами
+func (s *Service) SetTags(userID string, tags []string) (*models.User, error) {
+	return s.repo.SetTags(userID, models.NormalizeTags(tags))
+}
+
+// SetSeniority задает уровень старшинства пользователя. Пустой уровень сбрасывает его
+func (s *Service) SetSeniority(userID string, seniority string) (*models.User, error) {
+	if seniority != "" && !models.IsValidSeniority(seniority) {
+		return nil, errors.New("INVALID_SENIORITY: unknown seniority " + seniority)
 	}
+
+	return s.repo.SetSeniority(userID, seniority)
 }
 
-func (s *Service) SetIsActive(userID string, isActive bool) (*models.User, error) {
-	return s.repo.SetIsActive(userID, isActive)
+// Snooze откладывает назначение пользователя ревьювером до момента until. Пользователь остается активным
+// и сохраняет текущие ревью. nil снимает отсрочку
+func (s *Service) Snooze(userID string, until *time.Time) (*models.User, error) {
+	if until != nil && !until.After(s.clock.Now()) {
+		return nil, errors.New("INVALID_SNOOZE: until must be in the future")
+	}
+
+	user, err := s.repo.SetSnoozedUntil(userID, until)
+	if err != nil {
+		return nil, err
+	}
+
+	// Вернувшийся к назначениям пользователь может закрыть нехватку ревьюверов в открытых PR
+	if until == nil {
+		s.sweeper.Trigger()
+	}
+
+	return user, nil
 }
 
 func (s *Service) GetUserReviews(userID string) ([]models.PR, error) {
 	return s.repo.GetUserReviews(userID)
 }
+
+// CreateAbsence регистрирует период отсутствия пользователя. Если период уже начался,
+// ревью пользователя переназначаются сразу, не дожидаясь планировщика
+func (s *Service) CreateAbsence(userID string, startsAt, endsAt time.Time, reason string) (*models.Absence, error) {
+	if !endsAt.After(startsAt) {
+		return nil, errors.New("INVALID_ABSENCE: ends_at must be after starts_at")
+	}
+
+	if _, err := s.repo.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+
+	absence, err := s.repo.CreateAbsence(&models.Absence{
+		UserID:   userID,
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		Reason:   reason,
+		Status:   models.AbsenceStatusScheduled,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ошибка переназначения не отменяет отсутствие: планировщик повторит попытку
+	if err := s.syncAbsence(absence, s.clock.Now()); err != nil {
+		slog.Error("absence sync failed", "absence_id", absence.ID, "error", err)
+	}
+
+	return absence, nil
+}
+
+// ListAbsences возвращает периоды отсутствия пользователя
+func (s *Service) ListAbsences(userID string) ([]models.Absence, error) {
+	if _, err := s.repo.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListAbsences(userID)
+}
+
+// UpdateAbsence меняет границы и причину периода отсутствия. Статус пересчитывается по новым границам
+func (s *Service) UpdateAbsence(id uint, startsAt, endsAt time.Time, reason string) (*models.Absence, error) {
+	if !endsAt.After(startsAt) {
+		return nil, errors.New("INVALID_ABSENCE: ends_at must be after starts_at")
+	}
+
+	absence, err := s.repo.GetAbsence(id)
+	if err != nil {
+		return nil, err
+	}
+
+	absence.StartsAt = startsAt
+	absence.EndsAt = endsAt
+	absence.Reason = reason
+
+	if _, err := s.repo.UpdateAbsence(absence); err != nil {
+		return nil, err
+	}
+
+	// Ошибка переназначения не отменяет отсутствие: планировщик повторит попытку
+	if err := s.syncAbsence(absence, s.clock.Now()); err != nil {
+		slog.Error("absence sync failed", "absence_id", absence.ID, "error", err)
+	}
+
+	return absence, nil
+}
+
+// DeleteAbsence удаляет период отсутствия. Если он шел, пользователь сразу возвращается в ротацию
+func (s *Service) DeleteAbsence(id uint) error {
+	absence, err := s.repo.GetAbsence(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteAbsence(id); err != nil {
+		return err
+	}
+
+	if absence.Status == models.AbsenceStatusActive {
+		s.sweeper.Trigger()
+	}
+
+	return nil
+}
+
+// ImportAbsences создает периоды отсутствия из файла iCalendar. Пользователь события берется из X-USER-ID,
+// а если свойство не задано - из defaultUserID. Ошибочные события пропускаются и перечисляются в результате
+func (s *Service) ImportAbsences(r io.Reader, defaultUserID string) (*AbsenceImportResult, error) {
+	events, errs, err := parseCalendar(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AbsenceImportResult{
+		Imported: []models.Absence{},
+		Errors:   errs,
+	}
+
+	for _, event := range events {
+		userID := event.UserID
+		if userID == "" {
+			userID = defaultUserID
+		}
+		if userID == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("event %s: user is not specified", eventLabel(event)))
+			continue
+		}
+
+		absence, err := s.CreateAbsence(userID, event.StartsAt, event.EndsAt, event.Summary)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("event %s: %s", eventLabel(event), err))
+			continue
+		}
+		result.Imported = append(result.Imported, *absence)
+	}
+
+	return result, nil
+}
+
+// ProcessAbsences запускает начавшиеся отсутствия и завершает закончившиеся.
+// Возвращает число запущенных и завершенных периодов
+func (s *Service) ProcessAbsences() (int, int, error) {
+	now := s.clock.Now()
+
+	finished, err := s.repo.FinishAbsences(now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Вернувшиеся пользователи могут закрыть нехватку ревьюверов в открытых PR
+	if finished > 0 {
+		s.sweeper.Trigger()
+	}
+
+	due, err := s.repo.GetAbsencesToStart(now)
+	if err != nil {
+		return 0, int(finished), err
+	}
+
+	started := 0
+	var errs []error
+	for i := range due {
+		if err := s.startAbsence(&due[i]); err != nil {
+			errs = append(errs, fmt.Errorf("absence %d: %w", due[i].ID, err))
+			continue
+		}
+		started++
+	}
+
+	return started, int(finished), errors.Join(errs...)
+}
+
+// syncAbsence приводит статус отсутствия в соответствие с его границами на момент now
+func (s *Service) syncAbsence(absence *models.Absence, now time.Time) error {
+	wasActive := absence.Status == models.AbsenceStatusActive
+
+	switch {
+	case !absence.EndsAt.After(now):
+		absence.Status = models.AbsenceStatusFinished
+	case absence.StartsAt.After(now):
+		absence.Status = models.AbsenceStatusScheduled
+	case !wasActive:
+		return s.startAbsence(absence)
+	default:
+		return nil
+	}
+
+	if err := s.repo.SetAbsenceStatus(absence.ID, absence.Status); err != nil {
+		return err
+	}
+
+	if wasActive {
+		s.sweeper.Trigger()
+	}
+
+	return nil
+}
+
+// startAbsence переназначает ревью отсутствующего пользователя так же, как при деактивации,
+// но оставляет его активным, и отмечает отсутствие как начавшееся
+func (s *Service) startAbsence(absence *models.Absence) error {
+	if _, err := s.deactivator.DeactivateUser(absence.UserID, models.DeactivationOptions{KeepActive: true}); err != nil {
+		return err
+	}
+
+	absence.Status = models.AbsenceStatusActive
+	return s.repo.SetAbsenceStatus(absence.ID, absence.Status)
+}
+
+// eventLabel возвращает подпись события для сообщений об ошибках импорта
+func eventLabel(event CalendarEvent) string {
+	if event.Summary != "" {
+		return fmt.Sprintf("%d (%s)", event.Number, event.Summary)
+	}
+	return fmt.Sprintf("%d", event.Number)
+}
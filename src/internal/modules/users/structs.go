@@ -1,5 +1,11 @@
 package users
 
+import (
+	"time"
+
+	"github.com/tomatoCoderq/avito_task/src/models"
+)
+
 // UserActivationResult - результат операции активации/деактивации пользователя
 type UserActivationResult struct {
 	UserID      string `json:"user_id"`
@@ -9,6 +15,14 @@ type UserActivationResult struct {
 	ErrorReason string `json:"error_reason,omitempty"`
 }
 
+// ActivityOptions - параметры смены активности пользователя (внутренняя структура)
+type ActivityOptions struct {
+	// Deactivation применяется при деактивации
+	Deactivation models.DeactivationOptions
+	// RejoinRotation при реактивации возвращает пользователя в автоназначение ревьюверов
+	RejoinRotation bool
+}
+
 // UserReviewInfo - информация о ревью пользователя (внутренняя структура)
 type UserReviewInfo struct {
 	PRID       string `json:"pr_id"`
@@ -16,4 +30,20 @@ type UserReviewInfo struct {
 	AuthorID   string `json:"author_id"`
 	AuthorName string `json:"author_name"`
 	Status     string `json:"status"`
-}
\ No newline at end of file
+}
+
+// CalendarEvent - событие из файла iCalendar, из которого создается отсутствие (внутренняя структура)
+type CalendarEvent struct {
+	// Number - порядковый номер события в файле
+	Number   int
+	UserID   string
+	StartsAt time.Time
+	EndsAt   time.Time
+	Summary  string
+}
+
+// AbsenceImportResult - результат импорта отсутствий из календаря (внутренняя структура)
+type AbsenceImportResult struct {
+	Imported []models.Absence
+	Errors   []string
+}
@@ -0,0 +1,21 @@
+package sql
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordAssignments добавляет назначения ревьюверов PR в историю пар автор-ревьювер с моментом назначения at.
+// Используется репозиториями всех модулей, которые меняют ревьюверов PR
+func RecordAssignments(db *gorm.DB, prID string, reviewerIDs []string, at time.Time) error {
+	if len(reviewerIDs) == 0 {
+		return nil
+	}
+
+	return db.Exec(`
+		INSERT INTO review_assignments (pr_id, author_id, reviewer_id, team_id, assigned_at)
+		SELECT prs.id, prs.author_id, users.id, prs.team_id, ?
+		FROM prs JOIN users ON users.id IN ?
+		WHERE prs.id = ?`, at, reviewerIDs, prID).Error
+}
@@ -0,0 +1,8 @@
+package sql
+
+// NotAbsent отбирает пользователей, у которых в заданный момент не идет период отсутствия.
+// Момент передается параметром дважды, чтобы время бралось из часов сервиса, а не из БД
+const NotAbsent = "NOT EXISTS (SELECT 1 FROM absences WHERE absences.user_id = users.id AND absences.starts_at <= ? AND absences.ends_at > ?)"
+
+// NotSnoozed отбирает пользователей, у которых в заданный момент нет действующей отсрочки назначения
+const NotSnoozed = "(users.snoozed_until IS NULL OR users.snoozed_until <= ?)"
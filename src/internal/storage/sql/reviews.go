@@ -0,0 +1,35 @@
+package sql
+
+import (
+	"github.com/tomatoCoderq/avito_task/src/models"
+	"gorm.io/gorm"
+)
+
+// CountOpenReviews возвращает число открытых PR на ревью у каждого из пользователей.
+// Используется при выборе ревьюверов в модулях PR и команд
+func CountOpenReviews(db *gorm.DB, userIDs []string) (map[string]int, error) {
+	counts := make(map[string]int, len(userIDs))
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		UserID      string
+		ReviewCount int
+	}
+
+	if err := db.Table("pr_reviewers").
+		Select("pr_reviewers.user_id AS user_id, COUNT(*) AS review_count").
+		Joins("JOIN prs ON prs.id = pr_reviewers.pr_id").
+		Where("pr_reviewers.user_id IN ? AND prs.status = ?", userIDs, models.PRStatusOpen).
+		Group("pr_reviewers.user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.UserID] = row.ReviewCount
+	}
+
+	return counts, nil
+}
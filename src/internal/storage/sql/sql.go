@@ -24,7 +24,7 @@ func New(connectionString string) (*gorm.DB, error) {
 	sqlDB.SetConnMaxLifetime(10 * time.Minute)
 	sqlDB.SetConnMaxIdleTime(5 * time.Minute)
 
-	if err = db.AutoMigrate(&models.User{}, &models.Team{}, &models.PR{}); err != nil {
+	if err = db.AutoMigrate(&models.User{}, &models.Team{}, &models.TeamPolicy{}, &models.TeamFallback{}, &models.PR{}, &models.PRReview{}, &models.Deactivation{}, &models.DeactivationReassignment{}, &models.Absence{}, &models.CodeOwnerRule{}, &models.ReviewAssignment{}); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,20 @@
+package sql
+
+import "gorm.io/gorm"
+
+// UnitOfWork выполняет операции нескольких репозиториев в одной транзакции.
+// Репозитории подключаются к транзакции через свой метод WithTx
+type UnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{
+		db: db,
+	}
+}
+
+// Do выполняет fn в транзакции. Если fn возвращает ошибку, все изменения откатываются
+func (u *UnitOfWork) Do(fn func(tx *gorm.DB) error) error {
+	return u.db.Transaction(fn)
+}
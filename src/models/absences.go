@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Статусы отсутствия пользователя
+const (
+	AbsenceStatusScheduled = "SCHEDULED"
+	AbsenceStatusActive    = "ACTIVE"
+	AbsenceStatusFinished  = "FINISHED"
+)
+
+// Absence содержит период отсутствия пользователя. Пока период идет, пользователь не назначается ревьювером.
+// Статус меняет планировщик отсутствий. Модель используется для миграции
+type Absence struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    string    `gorm:"type:varchar(255);index"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
+	StartsAt  time.Time `gorm:"index"`
+	EndsAt    time.Time `gorm:"index"`
+	Reason    string
+	Status    string `gorm:"type:varchar(50);default:'SCHEDULED'"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ReviewAssignment - запись истории назначения ревьювера на PR. Записи не удаляются при снятии ревьювера,
+// поэтому по ним видно, кто кого ревьюил. Модель используется для миграции
+type ReviewAssignment struct {
+	ID         uint      `gorm:"primaryKey"`
+	PRID       string    `gorm:"type:varchar(255);index"`
+	AuthorID   string    `gorm:"type:varchar(255);index:idx_review_assignments_pair"`
+	ReviewerID string    `gorm:"type:varchar(255);index:idx_review_assignments_pair"`
+	TeamID     string    `gorm:"type:varchar(255);index"`
+	AssignedAt time.Time `gorm:"index"`
+}
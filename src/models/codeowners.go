@@ -0,0 +1,147 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// CodeOwnerRule - правило владения кодом команды в синтаксисе CODEOWNERS. Модель используется для миграции
+type CodeOwnerRule struct {
+	ID       uint   `gorm:"primaryKey"`
+	TeamID   string `gorm:"type:varchar(255);index"`
+	Position int
+	Pattern  string
+	Owners   []User `gorm:"many2many:code_owner_rule_owners;constraint:OnDelete:CASCADE;"`
+}
+
+// CodeOwnersEntry - разобранная строка файла CODEOWNERS. Владельцы указываются как @user_id
+type CodeOwnersEntry struct {
+	Line     int
+	Pattern  string
+	OwnerIDs []string
+}
+
+// ParseCodeOwners разбирает файл CODEOWNERS. Пустые строки и комментарии пропускаются,
+// строка без владельцев допустима и снимает владельцев с путей, как в CODEOWNERS
+func ParseCodeOwners(r io.Reader) ([]CodeOwnersEntry, error) {
+	entries := []CodeOwnersEntry{}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern := fields[0]
+		if strings.HasPrefix(pattern, "!") {
+			return nil, fmt.Errorf("INVALID_CODEOWNERS: line %d: negated patterns are not supported", line)
+		}
+		if _, err := codeOwnersRegexp(pattern); err != nil {
+			return nil, fmt.Errorf("INVALID_CODEOWNERS: line %d: invalid pattern %s", line, pattern)
+		}
+
+		ownerIDs := make([]string, 0, len(fields)-1)
+		for _, owner := range fields[1:] {
+			if !strings.HasPrefix(owner, "@") || len(owner) == 1 {
+				return nil, fmt.Errorf("INVALID_CODEOWNERS: line %d: owner %s must be @user_id", line, owner)
+			}
+			ownerIDs = append(ownerIDs, owner[1:])
+		}
+
+		entries = append(entries, CodeOwnersEntry{Line: line, Pattern: pattern, OwnerIDs: ownerIDs})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Matches сообщает, подходит ли путь под шаблон правила
+func (r *CodeOwnerRule) Matches(path string) bool {
+	re, err := codeOwnersRegexp(r.Pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(strings.TrimPrefix(path, "/"))
+}
+
+// MatchCodeOwnerRules возвращает правила, которые владеют измененными путями.
+// Как в CODEOWNERS, путь принадлежит последнему подходящему правилу. Правила возвращаются в порядке файла
+func MatchCodeOwnerRules(rules []CodeOwnerRule, paths []string) []CodeOwnerRule {
+	matched := make(map[int]bool)
+	for _, path := range paths {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].Matches(path) {
+				matched[i] = true
+				break
+			}
+		}
+	}
+
+	result := []CodeOwnerRule{}
+	for i, rule := range rules {
+		if matched[i] {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// codeOwnersRegexp переводит шаблон CODEOWNERS в регулярное выражение.
+// Шаблон со слешем в начале или середине привязан к корню, без слеша - подходит на любой глубине.
+// Шаблон каталога подходит и для всех файлов внутри него, а dir/* - только для файлов на первом уровне
+func codeOwnersRegexp(pattern string) (*regexp.Regexp, error) {
+	trimmed := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	if trimmed == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		switch {
+		case strings.HasPrefix(trimmed[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(trimmed[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case trimmed[i] == '*':
+			expr.WriteString("[^/]*")
+		case trimmed[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(trimmed[i : i+1]))
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		expr.WriteString("/.*$")
+	case strings.HasSuffix(trimmed, "/*"):
+		expr.WriteString("$")
+	default:
+		expr.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeOwnerRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// * не переходит через слеш, шаблон без слеша подходит на любой глубине
+		{"*.go", "main.go", true},
+		{"*.go", "src/internal/app.go", true},
+		{"*.go", "main.py", false},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/internal/app.go", false},
+
+		// ** подходит для любого числа каталогов
+		{"**/migrations", "migrations/001.sql", true},
+		{"**/migrations", "db/postgres/migrations/001.sql", true},
+		{"src/**/repo.go", "src/repo.go", true},
+		{"src/**/repo.go", "src/internal/modules/prs/repo.go", true},
+		{"src/**", "src/models/prs.go", true},
+		{"src/**", "docs/src.md", false},
+
+		// Слеш в начале привязывает шаблон к корню
+		{"/docs", "docs/readme.md", true},
+		{"/docs", "src/docs/readme.md", false},
+		{"docs", "src/docs/readme.md", true},
+		{"/README.md", "README.md", true},
+		{"/README.md", "docs/README.md", false},
+
+		// Слеш в конце подходит только для каталога и всего внутри него
+		{"build/", "build/out.bin", true},
+		{"build/", "src/build/out.bin", true},
+		{"build/", "build", false},
+		{"/build/", "src/build/out.bin", false},
+
+		// dir/* - только файлы первого уровня
+		{"docs/*", "docs/readme.md", true},
+		{"docs/*", "docs/api/openapi.yml", false},
+
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"file.go", "/file.go", true},
+		{"a+b.go", "a+b.go", true},
+		{"a+b.go", "aab.go", false},
+	}
+
+	for _, tt := range tests {
+		rule := CodeOwnerRule{Pattern: tt.pattern}
+		if got := rule.Matches(tt.path); got != tt.want {
+			t.Errorf("pattern %q, path %q: got %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCodeOwnerRules(t *testing.T) {
+	rules := []CodeOwnerRule{
+		{ID: 1, Pattern: "*"},
+		{ID: 2, Pattern: "*.go"},
+		{ID: 3, Pattern: "/src/internal/"},
+		{ID: 4, Pattern: "docs/*"},
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []uint
+	}{
+		{"last matching rule wins", []string{"src/internal/app.go"}, []uint{3}},
+		{"earlier rule when later ones do not match", []string{"main.go"}, []uint{2}},
+		{"catch-all rule", []string{"Makefile"}, []uint{1}},
+		{"rules are returned in file order", []string{"docs/readme.md", "main.go", "src/internal/x.txt"}, []uint{2, 3, 4}},
+		{"each rule is returned once", []string{"a.go", "b.go"}, []uint{2}},
+		{"no paths", nil, []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []uint{}
+			for _, rule := range MatchCodeOwnerRules(rules, tt.paths) {
+				got = append(got, rule.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rules %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCodeOwners(t *testing.T) {
+	input := strings.Join([]string{
+		"# владельцы репозитория",
+		"*        @lead",
+		"",
+		"*.go     @alice @bob # код на Go",
+		"/docs/",
+		"  ",
+	}, "\n")
+
+	entries, err := ParseCodeOwners(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCodeOwners: %v", err)
+	}
+
+	want := []CodeOwnersEntry{
+		{Line: 2, Pattern: "*", OwnerIDs: []string{"lead"}},
+		{Line: 4, Pattern: "*.go", OwnerIDs: []string{"alice", "bob"}},
+		{Line: 5, Pattern: "/docs/", OwnerIDs: []string{}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("got %+v, want %+v", entries, want)
+	}
+}
+
+func TestParseCodeOwnersErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"negated pattern", "*.go @alice\n!vendor/ @bob", "INVALID_CODEOWNERS: line 2: negated patterns"},
+		{"owner without @", "*.go alice", "INVALID_CODEOWNERS: line 1: owner alice must be @user_id"},
+		{"bare @", "*.go @", "INVALID_CODEOWNERS: line 1: owner @ must be @user_id"},
+		{"root-only pattern", "/ @alice", "INVALID_CODEOWNERS: line 1: invalid pattern /"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCodeOwners(strings.NewReader(tt.input))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got error %q, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
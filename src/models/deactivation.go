@@ -1,22 +1,137 @@
 package models
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Действия с открытыми PR, автор которых деактивируется
+const (
+	AuthoredPRActionTransfer = "transfer"
+	AuthoredPRActionClose    = "close"
+	AuthoredPRActionFlag     = "flag"
+)
+
+// DeactivationOptions задает дополнительные параметры массовой деактивации
+type DeactivationOptions struct {
+	// DryRun рассчитывает последствия деактивации, ничего не записывая в БД
+	DryRun bool
+	// AuthoredPRAction задает, что делать с открытыми PR деактивируемых авторов. Пустое значение оставляет их как есть
+	AuthoredPRAction string
+	// TransferTo - новый автор PR для AuthoredPRActionTransfer. Если не задан, PR передаются лиду команды
+	TransferTo string
+	// KeepActive переназначает ревью, не снимая с пользователей флаг активности, например на время отсутствия
+	KeepActive bool
+}
+
+// IsValidAuthoredPRAction проверяет, что действие с PR деактивируемых авторов поддерживается
+func IsValidAuthoredPRAction(action string) bool {
+	switch action {
+	case AuthoredPRActionTransfer, AuthoredPRActionClose, AuthoredPRActionFlag:
+		return true
+	}
+	return false
+}
+
 // DeactivationResult представляет результат операции массовой деактивации пользователей
 type DeactivationResult struct {
-	DeactivatedUsers []string              `json:"deactivated_users"`
-	ReassignedPRs    []PRReassignmentInfo  `json:"reassigned_prs"`
-	Errors           []string              `json:"errors,omitempty"`
+	DeactivationID   string               `json:"deactivation_id,omitempty"`
+	DryRun           bool                 `json:"dry_run"`
+	DeactivatedUsers []string             `json:"deactivated_users"`
+	ReassignedPRs    []PRReassignmentInfo `json:"reassigned_prs"`
+	UnderstaffedPRs  []string             `json:"understaffed_prs"`
+	UncoveredPRs     []string             `json:"uncovered_prs"`
+	AtCapacityPRs    []string             `json:"at_capacity_prs"`
+	// PolicyUnsatisfiedPRs - PR, где после замены ревьюверов перестало выполняться правило старшинства политики
+	PolicyUnsatisfiedPRs []string         `json:"policy_unsatisfied_prs"`
+	CandidateLoad        map[string]int   `json:"candidate_load"`
+	AuthoredPRs          []AuthoredPRInfo `json:"authored_prs"`
+	Errors               []string         `json:"errors,omitempty"`
 }
 
 // PRReassignmentInfo содержит информацию о переназначении ревьювера в PR
 type PRReassignmentInfo struct {
-	PRID         string `json:"pr_id"`
-	FromReviewer string `json:"from_reviewer"`
-	ToReviewer   string `json:"to_reviewer"`
+	PRID            string `json:"pr_id"`
+	FromReviewer    string `json:"from_reviewer"`
+	ToReviewer      string `json:"to_reviewer"`
+	ReplacementTeam string `json:"replacement_team"`
+}
+
+// AuthoredPRInfo содержит информацию о том, что стало с PR деактивированного автора
+type AuthoredPRInfo struct {
+	PRID        string `json:"pr_id"`
+	AuthorID    string `json:"author_id"`
+	Action      string `json:"action"`
+	NewAuthorID string `json:"new_author_id,omitempty"`
 }
 
-// ReassignmentData используется для батчевого переназначения ревьюверов
+// ReassignmentData используется для батчевого переназначения ревьюверов.
+// Пустой NewReviewerID означает, что ревьювер снимается без замены
 type ReassignmentData struct {
 	PRID          string
 	OldReviewerID string
 	NewReviewerID string
-}
\ No newline at end of file
+}
+
+// Deactivation - сохраненная партия деактивации вместе с выполненными заменами ревьюверов.
+// Модель используется для миграции
+type Deactivation struct {
+	ID            string                     `gorm:"type:varchar(255);primaryKey"`
+	TeamID        string                     `gorm:"type:varchar(255);index"`
+	Users         []User                     `gorm:"many2many:deactivation_users;constraint:OnDelete:CASCADE;"`
+	Reassignments []DeactivationReassignment `gorm:"constraint:OnDelete:CASCADE;"`
+	CreatedAt     time.Time
+}
+
+// BeforeCreate хук GORM, который генерирует UUID перед созданием записи
+func (d *Deactivation) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// DeactivationReassignment - замена ревьювера, выполненная при деактивации.
+// ResolvedAt заполняется, когда замена обработана при реактивации. Модель используется для миграции
+type DeactivationReassignment struct {
+	ID              uint   `gorm:"primaryKey"`
+	DeactivationID  string `gorm:"type:varchar(255);index"`
+	PRID            string `gorm:"type:varchar(255)"`
+	FromReviewer    string `gorm:"type:varchar(255);index"`
+	ToReviewer      string `gorm:"type:varchar(255)"`
+	ReplacementTeam string
+	ResolvedAt      *time.Time
+}
+
+// Причины, по которым замена ревьювера не откатывается при реактивации
+const (
+	RestoreSkipPRNotOpen       = "PR_NOT_OPEN"
+	RestoreSkipReviewSubmitted = "REVIEW_SUBMITTED"
+	RestoreSkipReviewerChanged = "REVIEWER_CHANGED"
+	RestoreSkipAlreadyAssigned = "ALREADY_ASSIGNED"
+	RestoreSkipAuthor          = "AUTHOR"
+)
+
+// ReactivationOptions задает дополнительные параметры массовой реактивации
+type ReactivationOptions struct {
+	// RestoreAssignments возвращает реактивированным пользователям ревью, переданные при деактивации
+	RestoreAssignments bool
+}
+
+// ReactivationResult представляет результат операции массовой реактивации пользователей
+type ReactivationResult struct {
+	ReactivatedUsers []string             `json:"reactivated_users"`
+	RestoredPRs      []PRReassignmentInfo `json:"restored_prs"`
+	SkippedPRs       []RestoreSkipInfo    `json:"skipped_prs"`
+	Errors           []string             `json:"errors,omitempty"`
+}
+
+// RestoreSkipInfo описывает замену ревьювера, которая не была откачена при реактивации
+type RestoreSkipInfo struct {
+	PRID              string `json:"pr_id"`
+	Reviewer          string `json:"reviewer"`
+	TemporaryReviewer string `json:"temporary_reviewer"`
+	Reason            string `json:"reason"`
+}
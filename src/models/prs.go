@@ -1,5 +1,26 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
+// Статусы PR
+const (
+	PRStatusDraft  = "DRAFT"
+	PRStatusOpen   = "OPEN"
+	PRStatusClosed = "CLOSED"
+	PRStatusMerged = "MERGED"
+)
+
+// prTransitions описывает допустимые переходы между статусами PR
+var prTransitions = map[string][]string{
+	PRStatusDraft:  {PRStatusOpen, PRStatusClosed},
+	PRStatusOpen:   {PRStatusMerged, PRStatusClosed},
+	PRStatusClosed: {PRStatusOpen},
+	PRStatusMerged: {},
+}
+
 // PR содержит информацию о pull request. Модель используется для миграции
 type PR struct {
 	ID                string `gorm:"type:varchar(255);primaryKey"`
@@ -7,6 +28,58 @@ type PR struct {
 	AuthorID          string `gorm:"type:varchar(255)"`
 	Author            User   `gorm:"foreignKey:AuthorID"`
 	Status            string `gorm:"type:varchar(50);default:'OPEN'"`
+	TeamID            string `gorm:"type:varchar(255);index"`
 	Reviewers         []User `gorm:"many2many:pr_reviewers;constraint:OnDelete:CASCADE;"`
 	NeedMoreReviewers bool
+	AuthorInactive    bool
+	ChangedPaths      []string `gorm:"type:text;serializer:json"`
+	RequiredTags      []string `gorm:"type:text;serializer:json"`
+	PolicyUnsatisfied bool
+	CreatedAt         time.Time `gorm:"index"`
+	UpdatedAt         time.Time
+	MergedAt          *time.Time
+}
+
+// UncoveredTags возвращает требуемые теги PR, которые не покрыты назначенными ревьюверами
+func (pr *PR) UncoveredTags() []string {
+	return UncoveredTags(pr.RequiredTags, pr.Reviewers)
+}
+
+// TransitionError возвращается при попытке недопустимой смены статуса PR
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: cannot change PR status from %s to %s", e.Code(), e.From, e.To)
+}
+
+// Code возвращает код ошибки для ответа API
+func (e *TransitionError) Code() string {
+	switch e.From {
+	case PRStatusMerged:
+		return "PR_MERGED"
+	case PRStatusClosed:
+		return "PR_CLOSED"
+	case PRStatusDraft:
+		return "PR_DRAFT"
+	}
+	return "INVALID_TRANSITION"
+}
+
+// IsValidPRStatus проверяет, что статус PR известен
+func IsValidPRStatus(status string) bool {
+	_, ok := prTransitions[status]
+	return ok
+}
+
+// CheckTransition проверяет, что PR может перейти из статуса from в статус to
+func CheckTransition(from, to string) error {
+	for _, allowed := range prTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to}
 }
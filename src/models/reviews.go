@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Вердикты ревью
+const (
+	VerdictApproved         = "APPROVED"
+	VerdictChangesRequested = "CHANGES_REQUESTED"
+	VerdictCommented        = "COMMENTED"
+)
+
+// PRReview содержит вердикт ревьювера по PR. Модель используется для миграции
+type PRReview struct {
+	ID         uint   `gorm:"primaryKey"`
+	PRID       string `gorm:"type:varchar(255);index"`
+	PR         PR     `gorm:"foreignKey:PRID;constraint:OnDelete:CASCADE;"`
+	ReviewerID string `gorm:"type:varchar(255);index"`
+	Reviewer   User   `gorm:"foreignKey:ReviewerID;constraint:OnDelete:CASCADE;"`
+	Verdict    string `gorm:"type:varchar(50)"`
+	Comment    string
+	CreatedAt  time.Time
+}
+
+// IsValidVerdict проверяет, что вердикт ревью поддерживается
+func IsValidVerdict(verdict string) bool {
+	switch verdict {
+	case VerdictApproved, VerdictChangesRequested, VerdictCommented:
+		return true
+	}
+	return false
+}
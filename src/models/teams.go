@@ -5,11 +5,21 @@ import (
 	"gorm.io/gorm"
 )
 
+// Стратегии выбора ревьюверов, доступные для команды
+const (
+	ReviewerStrategyRandom      = "random"
+	ReviewerStrategyRoundRobin  = "round_robin"
+	ReviewerStrategyLeastLoaded = "least_loaded"
+	ReviewerStrategyDiverse     = "diverse"
+)
+
 // Team содержит информацию о команде. Модель используется для миграции	
 type Team struct {
-	ID    string `gorm:"type:varchar(255);primaryKey"`
-	Name  string `gorm:"unique"`
-	Users []User `gorm:"many2many:team_users;"`
+	ID               string `gorm:"type:varchar(255);primaryKey"`
+	Name             string `gorm:"unique"`
+	ReviewerStrategy string `gorm:"type:varchar(50);default:'random'"`
+	LeadID           string `gorm:"type:varchar(255)"`
+	Users            []User `gorm:"many2many:team_users;"`
 }
 
 // BeforeCreate хук GORM, который генерирует UUID перед созданием записи
@@ -20,5 +30,93 @@ func (t *Team) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// Значения политики команды по умолчанию
+const (
+	DefaultReviewerCount = 2
+	DefaultMinApprovals  = 0
+)
+
+// TeamPolicy содержит правила назначения ревьюверов в команде. Модель используется для миграции
+type TeamPolicy struct {
+	TeamID         string `gorm:"type:varchar(255);primaryKey"`
+	ReviewerCount  int    `gorm:"default:2"`
+	MinApprovals   int    `gorm:"default:0"`
+	AllowCrossTeam bool
+	// SeniorReviewers - сколько ревьюверов PR должны иметь уровень не ниже SeniorityLevel
+	SeniorReviewers int    `gorm:"default:0"`
+	SeniorityLevel  string `gorm:"type:varchar(50);default:'senior'"`
+	// SeniorityAction - что делать, если правило старшинства не выполнить: отказать или пометить PR
+	SeniorityAction string         `gorm:"type:varchar(50);default:'fail'"`
+	ExcludedUsers   []User         `gorm:"many2many:team_policy_exclusions;joinForeignKey:TeamID;joinReferences:UserID;constraint:OnDelete:CASCADE;"`
+	FallbackTeams   []TeamFallback `gorm:"foreignKey:TeamID;references:TeamID;constraint:OnDelete:CASCADE;"`
+}
+
+// Действия при невыполненном правиле старшинства ревьюверов
+const (
+	SeniorityActionFail = "fail"
+	SeniorityActionFlag = "flag"
+)
+
+// TeamFallback задает команду, в которой ищутся ревьюверы, если в своей команде никто не подходит.
+// Команды просматриваются в порядке Position. Модель используется для миграции
+type TeamFallback struct {
+	TeamID         string `gorm:"type:varchar(255);primaryKey"`
+	FallbackTeamID string `gorm:"type:varchar(255);primaryKey"`
+	FallbackTeam   Team   `gorm:"foreignKey:FallbackTeamID;constraint:OnDelete:CASCADE;"`
+	Position       int
+}
+
+// DefaultTeamPolicy возвращает политику для команды, у которой она не сохранена
+func DefaultTeamPolicy(teamID string) *TeamPolicy {
+	return &TeamPolicy{
+		TeamID:        teamID,
+		ReviewerCount: DefaultReviewerCount,
+		MinApprovals:  DefaultMinApprovals,
+
+		SeniorityLevel:  SenioritySenior,
+		SeniorityAction: SeniorityActionFail,
+		ExcludedUsers:   []User{},
+		FallbackTeams:   []TeamFallback{},
+	}
+}
+
+// MissingSeniors возвращает, скольких активных ревьюверов уровня не ниже SeniorityLevel не хватает среди reviewers
+func (p *TeamPolicy) MissingSeniors(reviewers []User) int {
+	missing := p.SeniorReviewers
+	for i := range reviewers {
+		if reviewers[i].IsActive && reviewers[i].SeniorityAtLeast(p.SeniorityLevel) {
+			missing--
+		}
+	}
+	if missing < 0 {
+		return 0
+	}
+	return missing
+}
+
+// IsValidSeniorityAction проверяет, что действие при невыполненном правиле старшинства поддерживается
+func IsValidSeniorityAction(action string) bool {
+	return action == SeniorityActionFail || action == SeniorityActionFlag
+}
+
+// IsExcluded проверяет, исключен ли пользователь из автоназначения
+func (p *TeamPolicy) IsExcluded(userID string) bool {
+	for _, user := range p.ExcludedUsers {
+		if user.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidReviewerStrategy проверяет, что стратегия выбора ревьюверов поддерживается
+func IsValidReviewerStrategy(strategy string) bool {
+	switch strategy {
+	case ReviewerStrategyRandom, ReviewerStrategyRoundRobin, ReviewerStrategyLeastLoaded, ReviewerStrategyDiverse:
+		return true
+	}
+	return false
+}
+
 
 
@@ -1,9 +1,145 @@
 package models
 
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
 // User содержит информацию о пользователе. Модель используется для миграции
 type User struct {
 	ID       string `gorm:"type:varchar(255);primaryKey"`
 	Name     string
 	IsActive bool
-	Teams    []Team `gorm:"many2many:team_users;"`
-}
\ No newline at end of file
+	// OutOfRotation исключает активного пользователя из автоматического назначения ревьюверов
+	OutOfRotation bool
+	// MaxOpenReviews ограничивает число открытых PR на ревью у пользователя. 0 означает отсутствие лимита
+	MaxOpenReviews int
+	// PrimaryTeamID - основная команда пользователя, по которой подбираются ревьюверы, если команда PR не указана
+	PrimaryTeamID string `gorm:"type:varchar(255)"`
+	// Tags - навыки пользователя, по которым подбираются ревьюверы PR с требуемыми тегами
+	Tags []string `gorm:"type:text;serializer:json"`
+	// Seniority - уровень старшинства пользователя, пустой означает, что уровень не задан
+	Seniority string `gorm:"type:varchar(50)"`
+	// SnoozedUntil - до этого момента пользователь не назначается ревьювером, но остается активным
+	// и сохраняет текущие ревью. nil означает, что отсрочки нет
+	SnoozedUntil *time.Time
+	Teams        []Team `gorm:"many2many:team_users;"`
+}
+
+// Уровни старшинства пользователей по возрастанию
+const (
+	SeniorityJunior = "junior"
+	SeniorityMiddle = "middle"
+	SenioritySenior = "senior"
+	SeniorityLead   = "lead"
+)
+
+// seniorityRanks задает порядок уровней старшинства
+var seniorityRanks = map[string]int{
+	SeniorityJunior: 1,
+	SeniorityMiddle: 2,
+	SenioritySenior: 3,
+	SeniorityLead:   4,
+}
+
+// IsValidSeniority проверяет, что уровень старшинства поддерживается
+func IsValidSeniority(level string) bool {
+	_, ok := seniorityRanks[level]
+	return ok
+}
+
+// SeniorityAtLeast сообщает, что уровень пользователя задан и не ниже level
+func (u *User) SeniorityAtLeast(level string) bool {
+	rank := seniorityRanks[u.Seniority]
+	return rank > 0 && rank >= seniorityRanks[level]
+}
+
+// HasTag сообщает, есть ли у пользователя навык
+func (u *User) HasTag(tag string) bool {
+	for _, own := range u.Tags {
+		if own == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags приводит теги к нижнему регистру, убирает пустые и повторяющиеся и сортирует их
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// UncoveredTags возвращает требуемые теги, которых нет ни у одного из пользователей
+func UncoveredTags(required []string, users []User) []string {
+	uncovered := []string{}
+	for _, tag := range required {
+		covered := false
+		for i := range users {
+			if users[i].HasTag(tag) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			uncovered = append(uncovered, tag)
+		}
+	}
+	return uncovered
+}
+
+// PrimaryTeam возвращает основную команду пользователя. Если она не задана или пользователь
+// из нее вышел, берется команда с наименьшим именем, чтобы выбор не зависел от порядка загрузки
+func (u *User) PrimaryTeam() (Team, bool) {
+	if len(u.Teams) == 0 {
+		return Team{}, false
+	}
+
+	primary := u.Teams[0]
+	for _, team := range u.Teams {
+		if team.ID == u.PrimaryTeamID {
+			return team, true
+		}
+		if team.Name < primary.Name {
+			primary = team
+		}
+	}
+
+	return primary, true
+}
+
+// TeamByName возвращает команду пользователя с указанным именем
+func (u *User) TeamByName(name string) (Team, bool) {
+	for _, team := range u.Teams {
+		if team.Name == name {
+			return team, true
+		}
+	}
+	return Team{}, false
+}
+
+// InTeam сообщает, состоит ли пользователь в команде с указанным ID
+func (u *User) InTeam(teamID string) bool {
+	for _, team := range u.Teams {
+		if team.ID == teamID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSnoozed сообщает, отложено ли назначение пользователя ревьювером на момент now
+func (u *User) IsSnoozed(now time.Time) bool {
+	return u.SnoozedUntil != nil && u.SnoozedUntil.After(now)
+}